@@ -3,61 +3,225 @@ package main
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/admarc/users/cmd/server/config"
+	"github.com/admarc/users/db/migrations"
 	"github.com/admarc/users/internal/handlers"
+	"github.com/admarc/users/internal/health"
+	"github.com/admarc/users/internal/idempotency"
+	"github.com/admarc/users/internal/lifecycle"
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/internal/migrate"
+	"github.com/admarc/users/internal/sqldb"
+	storageIdempotency "github.com/admarc/users/internal/storage/idempotency"
+	pgIdempotency "github.com/admarc/users/internal/storage/idempotency/postgres"
 	storageUsers "github.com/admarc/users/internal/storage/users"
+	pgUsers "github.com/admarc/users/internal/storage/users/postgres"
+	"github.com/admarc/users/internal/tracing"
 	"github.com/admarc/users/internal/users"
 	"github.com/admarc/users/pkg/dbcollector"
+	"github.com/admarc/users/pkg/httpcollector"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	fmt.Println("starting")
-	defer fmt.Println("shutdown")
+	if err := run(); err != nil {
+		slog.Error("failed to run", "error", err)
+		os.Exit(1)
+	}
+}
 
+func run() error {
 	cfg, help, err := config.New()
 
 	if err != nil {
 		if help != "" {
-			log.Fatal(help)
+			fmt.Println(help)
+			return nil
 		}
-		log.Fatal(err)
+		return err
+	}
+
+	level := new(slog.LevelVar)
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		return fmt.Errorf("failed to parse log level: %w", err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	logger.Info("starting")
+	defer logger.Info("shutdown")
+
+	tp, err := tracing.NewProvider(context.Background(), tracing.Options{
+		ServiceName: cfg.Tracing.ServiceName,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return err
 	}
 
-	db := getDB(cfg)
+	module, system := cfg.DB.Driver, "sqlite"
+	if cfg.DB.Driver == config.PostgresDriver {
+		system = "postgresql"
+	}
+	queryCollector := dbcollector.NewQueryCollector("general", "main", module, cfg.Metrics.QueryDurationBuckets)
+	instrument := func(d driver.Driver) driver.Driver {
+		return dbcollector.WrapDriver(tracing.WrapDriver(d, system), queryCollector)
+	}
+
+	db, err := openDB(cfg.DB, false, instrument)
+	if err != nil {
+		return err
+	}
+	readDB := db
+	if cfg.DB.Driver == config.SQLiteDriver && cfg.DB.ReadMaxOpenConns > 0 {
+		if readDB, err = openDB(cfg.DB, true, instrument); err != nil {
+			return err
+		}
+	}
+	pingDB := func(ctx context.Context) error {
+		return errors.Join(db.PingContext(ctx), readDB.PingContext(ctx))
+	}
+	var (
+		dbCollectors []prometheus.Collector
+		maintenance  func(ctx context.Context) error
+	)
+	if cfg.DB.Driver == config.SQLiteDriver {
+		sqliteCollector := dbcollector.NewSQLiteCollector("general", "main", module, db, dbcollector.SQLiteOptions{
+			CheckpointInterval: cfg.DB.CheckpointInterval,
+			QuickCheckInterval: cfg.DB.QuickCheckInterval,
+		})
+		queryCollector.ObserveErrors(sqliteCollector.ObserveError)
+		dbCollectors = append(dbCollectors, sqliteCollector)
+		maintenance = sqliteCollector.Run
+	}
+
+	migrator, err := newMigrator(cfg.DB.Driver, db, cfg.Migrations.LockTimeout)
+	if err != nil {
+		return err
+	}
+
+	checks := health.NewRegistry(cfg.Health.CheckTimeout)
+	checks.Register("database", func(ctx context.Context) error {
+		if err := pingDB(ctx); err != nil {
+			return err
+		}
+		return migrator.Check(ctx)
+	}, health.Readiness, health.Startup)
+
+	txOpts, err := cfg.DB.Tx()
+	if err != nil {
+		return fmt.Errorf("invalid database config: %w", err)
+	}
+	slowQueries := dbcollector.NewSlowQueryCollector("general", "main", module)
+	retries := dbcollector.NewRetryCollector("general", "main", module)
+	sdb := sqldb.New(db, sqldb.Options{
+		Postgres:      cfg.DB.Driver == config.PostgresDriver,
+		SlowThreshold: cfg.DB.SlowQueryThreshold,
+		Explain:       cfg.DB.ExplainSlowQueries,
+		SlowQueries:   slowQueries,
+		ReadOnly:      readDB,
+		Retry: sqldb.RetryPolicy{
+			MaxAttempts: cfg.DB.RetryMaxAttempts,
+			BaseDelay:   cfg.DB.RetryBaseDelay,
+			MaxDelay:    cfg.DB.RetryMaxDelay,
+		},
+		Retries: retries,
+		Tx:      txOpts,
+	})
+	var (
+		repo users.Repository = storageUsers.NewStorage(sdb)
+		keys keyStore         = storageIdempotency.NewStorage(db)
+	)
+	if cfg.DB.Driver == config.PostgresDriver {
+		repo, keys = pgUsers.NewStorage(sdb), pgIdempotency.NewStorage(db)
+	}
+	us := users.NewService(repo, sdb)
+	uh := handlers.NewUsers(users.NewTracedService(us))
+	httpCollector := httpcollector.NewHTTPCollector("general", "http", httpcollector.Options{
+		DurationBuckets: cfg.Metrics.DurationBuckets,
+		SizeBuckets:     cfg.Metrics.SizeBuckets,
+		Exemplar: func(r *http.Request) prometheus.Labels {
+			if id := tracing.TraceID(r.Context()); id != "" {
+				return prometheus.Labels{"trace_id": id}
+			}
+			return nil
+		},
+	})
 
-	repo := storageUsers.NewStorage(&db)
-	us := users.NewService(repo)
-	uh := handlers.NewUsers(us)
 	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Use(httpCollector.Middleware)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(logging.Middleware(logger))
 	r.Use(middleware.Recoverer)
+	r.Use(handlers.MaxBodySize(cfg.HTTP.MaxBodyBytes))
+
+	r.Get("/healthz", checks.Handler(health.Liveness))
+	r.Get("/readyz", checks.Handler(health.Readiness))
+	r.Get("/startupz", checks.Handler(health.Startup))
 
 	r.Route("/users", func(r chi.Router) {
-		r.Post("/", uh.Create)
+		r.With(idempotency.Middleware(keys, cfg.Idempotency.TTL, cfg.Idempotency.LockTimeout)).Post("/", uh.Create)
+		r.Get("/", uh.List)
 		r.Get("/{id}", uh.Get)
+		r.Put("/{id}", uh.Update)
+		r.Patch("/{id}", uh.Patch)
+		r.Delete("/{id}", uh.Delete)
+		r.Post("/{id}:restore", uh.Restore)
+		r.Post("/{id}:activate", uh.Activate)
+		r.Post("/{id}:suspend", uh.Suspend)
+		r.Post("/{id}:lock", uh.Lock)
+		r.Get("/{id}/transitions", uh.Transitions)
 		r.Get("/test", func(w http.ResponseWriter, r *http.Request) {
 			time.Sleep(15 * time.Second)
 		})
 	})
 
-	prometheus.MustRegister(dbcollector.NewSQLDatabaseCollector("general", "main", "sqlite", &db))
-	r.Mount("/metrics", promhttp.Handler())
+	collectors := []prometheus.Collector{
+		dbcollector.NewSQLDatabaseCollector("general", "main", module, db),
+		queryCollector,
+		slowQueries,
+		retries,
+		httpCollector,
+	}
+	collectors = append(collectors, dbCollectors...)
+	if readDB != db {
+		collectors = append(collectors, dbcollector.NewSQLDatabaseCollector("general", "main", "sqlite_ro", readDB))
+	}
+	var queries []dbcollector.QueryDefinition
+	if cfg.Metrics.QueriesFile != "" {
+		if queries, err = dbcollector.LoadQueriesFile(cfg.Metrics.QueriesFile); err != nil {
+			return err
+		}
+	}
+	resultsCollector := dbcollector.NewQueryResultsCollector("general", "main", module, readDB, queries)
+	collectors = append(collectors, resultsCollector)
+	r.Mount("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 
-	s := http.Server{
+	s := &http.Server{
 		Addr:         cfg.HTTP.Addr,
 		Handler:      r,
 		ReadTimeout:  cfg.HTTP.ReadTimeout,
@@ -65,33 +229,227 @@ func main() {
 		IdleTimeout:  cfg.HTTP.IdleTimeout,
 	}
 
-	go func() {
-		fmt.Println(s.ListenAndServe())
-	}()
+	// The admin endpoints change how the service runs, they are served on a
+	// listener of their own and require a token.
+	if cfg.Admin.Token == "" {
+		logger.Warn("ADMIN_TOKEN is not set, the admin endpoints refuse every request")
+	}
+	ll := handlers.NewLogLevel(level)
+	ar := chi.NewRouter()
+	ar.Use(middleware.RequestID)
+	ar.Use(logging.Middleware(logger))
+	ar.Use(middleware.Recoverer)
+	ar.Use(handlers.MaxBodySize(cfg.HTTP.MaxBodyBytes))
+	ar.Use(handlers.RequireToken(cfg.Admin.Token))
+	ar.Route("/admin", func(r chi.Router) {
+		r.Get("/log-level", ll.Get)
+		r.Put("/log-level", ll.Put)
+	})
+	as := &http.Server{
+		Addr:         cfg.Admin.Addr,
+		Handler:      ar,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+
+	m := lifecycle.New(cfg.GracefulTimeout)
+	m.Add(
+		lifecycle.Component{
+			Name: "tracing",
+			Start: func(context.Context) error {
+				tracing.Install(tp)
+				return nil
+			},
+			Stop: tp.Shutdown,
+		},
+		lifecycle.Component{
+			Name: "database",
+			Start: func(ctx context.Context) error {
+				return migrateSchema(ctx, migrator, cfg.Migrations.Auto)
+			},
+			Ping: pingDB,
+			Stop: func(context.Context) error {
+				return errors.Join(readDB.Close(), db.Close())
+			},
+		},
+		lifecycle.Component{
+			Name: "metrics",
+			Start: func(context.Context) error {
+				for _, c := range collectors {
+					if err := prometheus.Register(c); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Run: resultsCollector.Run,
+			Stop: func(context.Context) error {
+				for _, c := range collectors {
+					prometheus.Unregister(c)
+				}
+				return nil
+			},
+		},
+		lifecycle.Component{
+			Name: "database maintenance",
+			Run:  maintenance,
+		},
+		lifecycle.Component{
+			Name: "purge worker",
+			Run: func(ctx context.Context) error {
+				l := logger.With("worker", "purge")
+				every(logging.NewContext(ctx, l), cfg.Purge.Interval, func(ctx context.Context) {
+					if _, err := us.Purge(ctx, cfg.Purge.Retention); err != nil {
+						l.Error("failed to purge users", "error", err)
+					}
+				})
+				return nil
+			},
+		},
+		lifecycle.Component{
+			Name: "idempotency key expiry worker",
+			Run: func(ctx context.Context) error {
+				l := logger.With("worker", "idempotency_expiry")
+				every(logging.NewContext(ctx, l), cfg.Idempotency.ExpiryInterval, func(ctx context.Context) {
+					if _, err := keys.DeleteExpired(ctx, time.Now()); err != nil {
+						l.Error("failed to delete expired idempotency keys", "error", err)
+					}
+				})
+				return nil
+			},
+		},
+		httpServer("admin http server", as),
+		httpServer("http server", s),
+		readiness(checks, cfg.Health.DrainDelay),
+	)
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer stop()
 
-	<-ctx.Done()
-	fmt.Println("signal received")
+	return m.Run(ctx)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.GracefulTimeout)
-	defer cancel()
+// httpServer listens on s.Addr when started, so that a taken port fails the
+// startup, and serves until stopped.
+func httpServer(name string, s *http.Server) lifecycle.Component {
+	var ln net.Listener
+	return lifecycle.Component{
+		Name: name,
+		Start: func(context.Context) error {
+			var err error
+			ln, err = net.Listen("tcp", s.Addr)
+			return err
+		},
+		Run: func(context.Context) error {
+			if err := s.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		Stop: s.Shutdown,
+	}
+}
 
-	fmt.Println("shutting down")
-	s.Shutdown(ctx)
+// readiness is started last and so stopped first. It marks the application
+// as started and, on shutdown, fails the readiness probe for drainDelay before
+// letting the HTTP server stop.
+func readiness(checks *health.Registry, drainDelay time.Duration) lifecycle.Component {
+	return lifecycle.Component{
+		Name: "readiness",
+		Start: func(context.Context) error {
+			checks.Started()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			checks.ShuttingDown()
+
+			t := time.NewTimer(drainDelay)
+			defer t.Stop()
+			select {
+			case <-t.C:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
 }
 
-func getDB(cfg config.Config) sql.DB {
-	db, err := sql.Open("sqlite3", cfg.DB.DSN)
+// keyStore stores idempotency keys and deletes the expired ones.
+type keyStore interface {
+	idempotency.Store
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// openDB opens a pool of connections to cfg.DSN, read-only ones if readOnly
+// is set, through the driver returned by wrap.
+func openDB(cfg config.DB, readOnly bool, wrap func(driver.Driver) driver.Driver) (*sql.DB, error) {
+	var d driver.Driver = &pq.Driver{}
+	if cfg.Driver == config.SQLiteDriver {
+		pragmas := cfg.Pragmas(readOnly)
+		if err := pragmas.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid database config: %w", err)
+		}
+		d = &sqlite3.SQLiteDriver{ConnectHook: pragmas.ConnectHook}
+	}
+
+	db := sql.OpenDB(sqldb.Connector(wrap(d), cfg.DSN))
+	if readOnly {
+		db.SetMaxIdleConns(cfg.ReadMaxIdleConns)
+		db.SetMaxOpenConns(cfg.ReadMaxOpenConns)
+	} else {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// newMigrator returns the migrator of the schema of driver.
+func newMigrator(driver string, db *sql.DB, lockTimeout time.Duration) (migrate.Migrator, error) {
+	if driver == config.PostgresDriver {
+		ms, err := migrate.Load(migrations.Postgres, "postgres")
+		if err != nil {
+			return migrate.Migrator{}, err
+		}
+		return migrate.NewPostgres(db, ms, lockTimeout), nil
+	}
+
+	ms, err := migrate.Load(migrations.FS, ".")
 	if err != nil {
-		log.Fatal(err)
+		return migrate.Migrator{}, err
 	}
-	defer db.Close()
-	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
-	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
-	db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
-	db.SetConnMaxIdleTime(cfg.DB.ConnMaxIdleTime)
+	return migrate.New(db, ms, lockTimeout), nil
+}
 
-	return *db
+// migrateSchema applies pending migrations when auto is set and otherwise
+// fails if there are any.
+func migrateSchema(ctx context.Context, m migrate.Migrator, auto bool) error {
+	if !auto {
+		return m.Check(ctx)
+	}
+
+	applied, err := m.Up(ctx)
+	for _, mig := range applied {
+		slog.Info("applied migration", "version", mig.Version, "name", mig.Name)
+	}
+	return err
+}
+
+// every runs fn each interval until ctx is done.
+func every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			fn(ctx)
+		}
+	}
 }
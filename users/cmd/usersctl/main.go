@@ -0,0 +1,142 @@
+// Command usersctl runs operational tasks against the users database: schema
+// migrations, looking up and creating users and seeding fake users for load
+// tests. It reads the same configuration as the server.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/admarc/users/cmd/server/config"
+	"github.com/admarc/users/db/migrations"
+	"github.com/admarc/users/internal/migrate"
+	"github.com/admarc/users/internal/sqldb"
+	"github.com/ardanlabs/conf/v3"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+const usage = `
+Usage: usersctl [flags] command [arguments]
+
+Commands:
+  migrate up|down|status|redo    apply, roll back, list or redo migrations
+  user create --name NAME --email EMAIL [--given-name NAME] [--family-name NAME] [--status STATUS]
+  user get ID
+  seed [--count N]               create N fake users
+
+Flags such as --output json go before the command.
+`
+
+type Config struct {
+	config.Config
+	Output string `conf:"default:table,short:o,help:output format: table or json"`
+	Args   conf.Args
+}
+
+func main() {
+	if err := run(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "usersctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer) error {
+	cfg := Config{}
+	if help, err := conf.Parse("", &cfg); err != nil {
+		if errors.Is(err, conf.ErrHelpWanted) {
+			fmt.Fprint(w, help, usage)
+			return nil
+		}
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := cfg.DB.Validate(); err != nil {
+		return fmt.Errorf("invalid database config: %w", err)
+	}
+
+	out, err := newPrinter(w, cfg.Output)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := openDB(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := newMigrator(cfg.DB.Driver, db, cfg.Migrations.LockTimeout)
+	if err != nil {
+		return err
+	}
+
+	cmd, args := cfg.Args.Num(0), []string(cfg.Args)
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	switch cmd {
+	case "migrate":
+		return runMigrate(ctx, m, args, out)
+	case "user", "seed":
+		// Commands working with users need an up to date schema.
+		if err := m.Check(ctx); err != nil {
+			return fmt.Errorf("%w, run usersctl migrate up first", err)
+		}
+		us, tx := newService(cfg.DB.Driver, db)
+		if cmd == "user" {
+			return runUser(ctx, us, args, out)
+		}
+		return runSeed(ctx, us, tx, args, out)
+	case "":
+		return fmt.Errorf("missing command\n%s", usage)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", cmd, usage)
+	}
+}
+
+func openDB(cfg config.DB) (*sql.DB, error) {
+	var d driver.Driver = &pq.Driver{}
+	if cfg.Driver == config.SQLiteDriver {
+		pragmas := cfg.Pragmas(false)
+		if err := pragmas.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid database config: %w", err)
+		}
+		d = &sqlite3.SQLiteDriver{ConnectHook: pragmas.ConnectHook}
+	}
+
+	db := sql.OpenDB(sqldb.Connector(d, cfg.DSN))
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// newMigrator returns the migrator of the schema of driver.
+func newMigrator(driver string, db *sql.DB, lockTimeout time.Duration) (migrate.Migrator, error) {
+	if driver == config.PostgresDriver {
+		ms, err := migrate.Load(migrations.Postgres, "postgres")
+		if err != nil {
+			return migrate.Migrator{}, err
+		}
+		return migrate.NewPostgres(db, ms, lockTimeout), nil
+	}
+
+	ms, err := migrate.Load(migrations.FS, ".")
+	if err != nil {
+		return migrate.Migrator{}, err
+	}
+	return migrate.New(db, ms, lockTimeout), nil
+}
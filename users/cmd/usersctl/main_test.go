@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		migrate    bool
+		wantErrMsg string
+		wantOut    string
+	}{
+		{
+			name:       "missing command",
+			args:       nil,
+			wantErrMsg: "missing command",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"frob"},
+			wantErrMsg: `unknown command "frob"`,
+		},
+		{
+			name:       "unknown output format",
+			args:       []string{"--output", "xml", "migrate", "status"},
+			wantErrMsg: `unknown output format "xml"`,
+		},
+		{
+			name:       "migrate without subcommand",
+			args:       []string{"migrate"},
+			wantErrMsg: "usage: usersctl migrate up|down|status|redo",
+		},
+		{
+			name:       "unknown migrate command",
+			args:       []string{"migrate", "sideways"},
+			wantErrMsg: `unknown migrate command "sideways"`,
+		},
+		{
+			name:       "redo without migrations",
+			args:       []string{"migrate", "redo"},
+			wantErrMsg: "no migration to redo",
+		},
+		{
+			name:       "users need the schema",
+			args:       []string{"user", "get", "1"},
+			wantErrMsg: "run usersctl migrate up first",
+		},
+		{
+			name:       "user without subcommand",
+			args:       []string{"user"},
+			migrate:    true,
+			wantErrMsg: "usage: usersctl user create|get",
+		},
+		{
+			name:       "unknown user command",
+			args:       []string{"user", "rename"},
+			migrate:    true,
+			wantErrMsg: `unknown user command "rename"`,
+		},
+		{
+			name:       "user get without id",
+			args:       []string{"user", "get"},
+			migrate:    true,
+			wantErrMsg: "usage: usersctl user get ID",
+		},
+		{
+			name:       "user get unknown id",
+			args:       []string{"user", "get", "1"},
+			migrate:    true,
+			wantErrMsg: "not found",
+		},
+		{
+			name:       "user create with invalid status",
+			args:       []string{"user", "create", "--name", "tod", "--email", "tod@example.com", "--status", "locked"},
+			migrate:    true,
+			wantErrMsg: `a user can't be created as "locked"`,
+		},
+		{
+			name:       "seed with invalid count",
+			args:       []string{"seed", "--count", "many"},
+			migrate:    true,
+			wantErrMsg: `invalid value "many" for flag -count`,
+		},
+		{
+			name:    "migrate status",
+			args:    []string{"-o", "json", "migrate", "status"},
+			wantOut: `"applied": false`,
+		},
+		{
+			name:    "migrate up",
+			args:    []string{"migrate", "up"},
+			wantOut: "20221202132635  user",
+		},
+		{
+			name:    "user create",
+			args:    []string{"user", "create", "--name", "tod", "--email", "TOD@example.com"},
+			migrate: true,
+			wantOut: "tod@example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_DSN", filepath.Join(t.TempDir(), "db.sqlite3"))
+			if tt.migrate {
+				runArgs(t, "migrate", "up")
+			}
+
+			out, err := runArgs(t, tt.args...)
+			if tt.wantErrMsg != "" {
+				assert.ErrorContains(t, err, tt.wantErrMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, out, tt.wantOut)
+		})
+	}
+}
+
+// runArgs runs usersctl with args as command line arguments and returns what
+// it printed.
+func runArgs(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	osArgs := os.Args
+	os.Args = append([]string{"usersctl"}, args...)
+	defer func() { os.Args = osArgs }()
+
+	var out bytes.Buffer
+	err := run(&out)
+	return out.String(), err
+}
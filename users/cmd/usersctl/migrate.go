@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/admarc/users/internal/migrate"
+)
+
+type migrationResult struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+func runMigrate(ctx context.Context, m migrate.Migrator, args []string, out printer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: usersctl migrate up|down|status|redo")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if printErr := printMigrations(out, applied, true); printErr != nil && err == nil {
+			err = printErr
+		}
+		return err
+	case "down":
+		mig, ok, err := m.Down(ctx)
+		if err != nil || !ok {
+			return err
+		}
+		return printMigrations(out, []migrate.Migration{mig}, false)
+	case "redo":
+		mig, ok, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no migration to redo")
+		}
+		if _, err := m.Up(ctx); err != nil {
+			return err
+		}
+		return printMigrations(out, []migrate.Migration{mig}, true)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		results := make([]migrationResult, 0, len(statuses))
+		for _, s := range statuses {
+			results = append(results, migrationResult{Version: s.Version, Name: s.Name, Applied: s.Applied})
+		}
+		return printResults(out, results)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func printMigrations(out printer, ms []migrate.Migration, applied bool) error {
+	results := make([]migrationResult, 0, len(ms))
+	for _, mig := range ms {
+		results = append(results, migrationResult{Version: mig.Version, Name: mig.Name, Applied: applied})
+	}
+	return printResults(out, results)
+}
+
+func printResults(out printer, results []migrationResult) error {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, []string{r.Version, r.Name, fmt.Sprint(r.Applied)})
+	}
+	return out.print(results, []string{"VERSION", "NAME", "APPLIED"}, rows)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/admarc/users/internal/models"
+)
+
+// printer writes results either as an aligned table or as JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (printer, error) {
+	switch format {
+	case "table":
+		return printer{w: w}, nil
+	case "json":
+		return printer{w: w, json: true}, nil
+	default:
+		return printer{}, fmt.Errorf("unknown output format %q, use table or json", format)
+	}
+}
+
+// print writes v as JSON or header and rows as a table.
+func (p printer) print(v any, header []string, rows [][]string) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (p printer) users(users ...models.User) error {
+	header := []string{"ID", "NAME", "EMAIL", "STATUS", "VERSION", "CREATED_AT"}
+	rows := make([][]string, 0, len(users))
+	for _, u := range users {
+		rows = append(rows, []string{u.ID, u.Name, u.Email, string(u.Status), fmt.Sprint(u.Version), u.CreatedAt.Format(time.RFC3339)})
+	}
+
+	var v any = users
+	if len(users) == 1 {
+		v = users[0]
+	}
+	return p.print(v, header, rows)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPrinter(t *testing.T) {
+	for _, format := range []string{"table", "json"} {
+		_, err := newPrinter(&bytes.Buffer{}, format)
+		assert.NoError(t, err, format)
+	}
+
+	_, err := newPrinter(&bytes.Buffer{}, "yaml")
+	assert.EqualError(t, err, `unknown output format "yaml", use table or json`)
+}
+
+func TestPrinter_users(t *testing.T) {
+	createdAt := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	tod := models.User{ID: "1", Name: "tod", Email: "tod@example.com", Status: models.UserStatusActive, Version: 1, CreatedAt: createdAt, UpdatedAt: createdAt}
+	anna := models.User{ID: "2", Name: "anna lund", Status: models.UserStatusPending, Version: 12, CreatedAt: createdAt, UpdatedAt: createdAt}
+
+	tests := []struct {
+		name   string
+		format string
+		users  []models.User
+		want   string
+	}{
+		{
+			name:   "table",
+			format: "table",
+			users:  []models.User{tod, anna},
+			want: "ID  NAME       EMAIL            STATUS   VERSION  CREATED_AT\n" +
+				"1   tod        tod@example.com  active   1        2026-10-18T09:30:00Z\n" +
+				"2   anna lund                   pending  12       2026-10-18T09:30:00Z\n",
+		},
+		{
+			name:   "json of one user",
+			format: "json",
+			users:  []models.User{tod},
+			want: `{
+  "id": "1",
+  "name": "tod",
+  "email": "tod@example.com",
+  "status": "active",
+  "version": 1,
+  "created_at": "2026-10-18T09:30:00Z",
+  "updated_at": "2026-10-18T09:30:00Z"
+}
+`,
+		},
+		{
+			name:   "json of several users",
+			format: "json",
+			users:  []models.User{tod, anna},
+			want: `[
+  {
+    "id": "1",
+    "name": "tod",
+    "email": "tod@example.com",
+    "status": "active",
+    "version": 1,
+    "created_at": "2026-10-18T09:30:00Z",
+    "updated_at": "2026-10-18T09:30:00Z"
+  },
+  {
+    "id": "2",
+    "name": "anna lund",
+    "status": "pending",
+    "version": 12,
+    "created_at": "2026-10-18T09:30:00Z",
+    "updated_at": "2026-10-18T09:30:00Z"
+  }
+]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p, err := newPrinter(&out, tt.format)
+			require.NoError(t, err)
+
+			require.NoError(t, p.users(tt.users...))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestPrintResults(t *testing.T) {
+	results := []migrationResult{{Version: "20221202132635", Name: "user", Applied: true}}
+
+	var out bytes.Buffer
+	require.NoError(t, printResults(printer{w: &out}, results))
+	assert.Equal(t, "VERSION         NAME  APPLIED\n20221202132635  user  true\n", out.String())
+
+	out.Reset()
+	require.NoError(t, printResults(printer{w: &out, json: true}, results))
+	assert.JSONEq(t, `[{"version": "20221202132635", "name": "user", "applied": true}]`, out.String())
+}
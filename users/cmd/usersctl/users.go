@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/admarc/users/cmd/server/config"
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/sqldb"
+	storageUsers "github.com/admarc/users/internal/storage/users"
+	pgUsers "github.com/admarc/users/internal/storage/users/postgres"
+	"github.com/admarc/users/internal/users"
+	"github.com/google/uuid"
+)
+
+// newService returns the users service on db, a database of driver, and the
+// transactor its storage joins.
+func newService(driver string, db *sql.DB) (users.Service, users.Transactor) {
+	sdb := sqldb.New(db, sqldb.Options{Postgres: driver == config.PostgresDriver})
+	if driver == config.PostgresDriver {
+		return users.NewService(pgUsers.NewStorage(sdb), sdb), sdb
+	}
+	return users.NewService(storageUsers.NewStorage(sdb), sdb), sdb
+}
+
+func runUser(ctx context.Context, us users.Service, args []string, out printer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: usersctl user create|get")
+	}
+
+	switch args[0] {
+	case "create":
+		var usr models.User
+		var status string
+		fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+		fs.StringVar(&usr.Name, "name", "", "display name")
+		fs.StringVar(&usr.Email, "email", "", "email address")
+		fs.StringVar(&usr.GivenName, "given-name", "", "given name")
+		fs.StringVar(&usr.FamilyName, "family-name", "", "family name")
+		fs.StringVar(&status, "status", string(models.UserStatusActive), "pending or active")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		usr.Status = models.UserStatus(status)
+
+		usr, err := us.Create(ctx, usr)
+		if err != nil {
+			return err
+		}
+		return out.users(usr)
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: usersctl user get ID")
+		}
+		usr, err := us.Get(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return out.users(usr)
+	default:
+		return fmt.Errorf("unknown user command %q", args[0])
+	}
+}
+
+var (
+	givenNames  = []string{"Anna", "Ben", "Clara", "David", "Emma", "Felix", "Grace", "Hugo", "Iris", "Jonas", "Lena", "Mike", "Nora", "Oskar", "Paula", "Tod"}
+	familyNames = []string{"Berg", "Costa", "Dahl", "Evans", "Fischer", "Garcia", "Hansen", "Ito", "Jensen", "Kowalski", "Lund", "Moreau", "Novak", "Smith"}
+)
+
+type seedResult struct {
+	Created  int    `json:"created"`
+	Duration string `json:"duration"`
+}
+
+// runSeed creates fake users with unique example.com addresses in one
+// transaction, so that a failed seed creates none.
+func runSeed(ctx context.Context, us users.Service, tx users.Transactor, args []string, out printer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	count := fs.Int("count", 100, "number of users to create")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	start := time.Now()
+	created := 0
+	err := tx.InTx(ctx, func(ctx context.Context) error {
+		for ; created < *count; created++ {
+			given := givenNames[rnd.Intn(len(givenNames))]
+			family := familyNames[rnd.Intn(len(familyNames))]
+			_, err := us.Create(ctx, models.User{
+				Name:       given + " " + family,
+				GivenName:  given,
+				FamilyName: family,
+				Email:      fmt.Sprintf("%s.%s.%s@example.com", strings.ToLower(given), strings.ToLower(family), uuid.NewString()[:8]),
+			})
+			if err != nil {
+				return fmt.Errorf("failed after %d users: %w", created, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	res := seedResult{Created: created, Duration: time.Since(start).Round(time.Millisecond).String()}
+	return out.print(res, []string{"CREATED", "DURATION"}, [][]string{{fmt.Sprint(res.Created), res.Duration}})
+}
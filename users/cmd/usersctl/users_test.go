@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/admarc/users/cmd/server/config"
+	"github.com/admarc/users/db/migrations"
+	"github.com/admarc/users/internal/migrate"
+	"github.com/admarc/users/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMemoryDB returns an in-memory database with the current schema. It has
+// a single connection, each connection to :memory: is a database of its own.
+func newMemoryDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	ms, err := migrate.Load(migrations.FS, ".")
+	require.NoError(t, err)
+	_, err = migrate.New(db, ms, time.Second).Up(context.Background())
+	require.NoError(t, err)
+
+	return db
+}
+
+func countUsers(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM users").Scan(&n))
+	return n
+}
+
+func TestRunSeed(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantErrMsg string
+		wantUsers  int
+	}{
+		{name: "default count", args: nil, wantUsers: 100},
+		{name: "count", args: []string{"--count", "7"}, wantUsers: 7},
+		{name: "no users", args: []string{"--count", "0"}, wantUsers: 0},
+		{name: "unknown flag", args: []string{"--size", "7"}, wantErrMsg: "flag provided but not defined: -size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newMemoryDB(t)
+			us, tx := newService(config.SQLiteDriver, db)
+
+			var out bytes.Buffer
+			err := runSeed(context.Background(), us, tx, tt.args, printer{w: &out, json: true})
+			if tt.wantErrMsg != "" {
+				assert.ErrorContains(t, err, tt.wantErrMsg)
+				return
+			}
+			require.NoError(t, err)
+
+			var res seedResult
+			require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+			assert.Equal(t, tt.wantUsers, res.Created)
+			assert.Equal(t, tt.wantUsers, countUsers(t, db))
+		})
+	}
+}
+
+func TestRunSeed_rollback(t *testing.T) {
+	db := newMemoryDB(t)
+	us, tx := newService(config.SQLiteDriver, db)
+
+	// The only connection of db gets a trigger failing the third insert.
+	_, err := db.Exec(`CREATE TEMP TRIGGER fail_seed BEFORE INSERT ON users
+		WHEN (SELECT count(*) FROM users) = 2 BEGIN SELECT raise(ABORT, 'seed failed'); END`)
+	require.NoError(t, err)
+
+	err = runSeed(context.Background(), us, tx, []string{"--count", "5"}, printer{w: &bytes.Buffer{}})
+	assert.ErrorContains(t, err, "failed after 2 users")
+	assert.Equal(t, 0, countUsers(t, db), "the users created before the failure are rolled back")
+}
+
+func TestRunUser(t *testing.T) {
+	db := newMemoryDB(t)
+	us, _ := newService(config.SQLiteDriver, db)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	err := runUser(ctx, us, []string{"create", "--name", "tod", "--email", "TOD@example.com", "--status", "pending"}, printer{w: &out, json: true})
+	require.NoError(t, err)
+
+	var created models.User
+	require.NoError(t, json.Unmarshal(out.Bytes(), &created))
+	assert.Equal(t, "tod@example.com", created.Email)
+	assert.Equal(t, models.UserStatusPending, created.Status)
+
+	out.Reset()
+	require.NoError(t, runUser(ctx, us, []string{"get", created.ID}, printer{w: &out, json: true}))
+	var got models.User
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, created.ID, got.ID)
+
+	err = runUser(ctx, us, []string{"create", "--name", "mike", "--email", "tod@example.com"}, printer{w: &out})
+	assert.ErrorIs(t, err, models.UserEmailTakenErr)
+}
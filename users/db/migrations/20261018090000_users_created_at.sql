@@ -0,0 +1,10 @@
+-- migrate:up
+ALTER TABLE `users` ADD COLUMN `created_at` datetime NULL;
+UPDATE `users` SET `created_at` = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE `created_at` IS NULL;
+CREATE INDEX `users_created_at_id_idx` ON `users` (`created_at`, `id`);
+CREATE INDEX `users_name_id_idx` ON `users` (`name`, `id`);
+
+-- migrate:down
+DROP INDEX `users_name_id_idx`;
+DROP INDEX `users_created_at_id_idx`;
+ALTER TABLE `users` DROP COLUMN `created_at`;
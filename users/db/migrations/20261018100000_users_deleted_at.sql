@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE `users` ADD COLUMN `deleted_at` datetime NULL;
+CREATE INDEX `users_deleted_at_idx` ON `users` (`deleted_at`);
+
+-- migrate:down
+DROP INDEX `users_deleted_at_idx`;
+ALTER TABLE `users` DROP COLUMN `deleted_at`;
@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE `users` ADD COLUMN `version` integer NOT NULL DEFAULT 1;
+
+-- migrate:down
+ALTER TABLE `users` DROP COLUMN `version`;
@@ -0,0 +1,14 @@
+-- migrate:up
+CREATE TABLE `idempotency_keys` (
+  `key` TEXT PRIMARY KEY,
+  `request_hash` TEXT NOT NULL,
+  `status` integer NULL,
+  `content_type` TEXT NULL,
+  `body` BLOB NULL,
+  `created_at` datetime NOT NULL,
+  `expires_at` datetime NOT NULL
+);
+CREATE INDEX `idempotency_keys_expires_at_idx` ON `idempotency_keys` (`expires_at`);
+
+-- migrate:down
+drop table idempotency_keys;
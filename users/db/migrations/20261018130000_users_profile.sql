@@ -0,0 +1,44 @@
+-- migrate:up
+CREATE TABLE `users_new` (
+  `id` TEXT NOT NULL PRIMARY KEY,
+  `name` TEXT NOT NULL DEFAULT '',
+  `given_name` TEXT NOT NULL DEFAULT '',
+  `family_name` TEXT NOT NULL DEFAULT '',
+  `email` TEXT NULL COLLATE NOCASE,
+  `status` TEXT NOT NULL DEFAULT 'active',
+  `version` integer NOT NULL DEFAULT 1,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `deleted_at` datetime NULL
+);
+INSERT INTO `users_new` (`id`, `name`, `version`, `created_at`, `updated_at`, `deleted_at`)
+SELECT
+  `id`,
+  COALESCE(`name`, ''),
+  `version`,
+  COALESCE(`created_at`, strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+  COALESCE(`deleted_at`, `created_at`, strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+  `deleted_at`
+FROM `users`;
+DROP TABLE `users`;
+ALTER TABLE `users_new` RENAME TO `users`;
+CREATE INDEX `users_created_at_id_idx` ON `users` (`created_at`, `id`);
+CREATE INDEX `users_name_id_idx` ON `users` (`name`, `id`);
+CREATE INDEX `users_deleted_at_idx` ON `users` (`deleted_at`);
+CREATE UNIQUE INDEX `users_email_idx` ON `users` (`email`);
+
+-- migrate:down
+CREATE TABLE `users_old` (
+  `id` string PRIMARY KEY,
+  `name` string NULL,
+  `created_at` datetime NULL,
+  `deleted_at` datetime NULL,
+  `version` integer NOT NULL DEFAULT 1
+);
+INSERT INTO `users_old` (`id`, `name`, `created_at`, `deleted_at`, `version`)
+SELECT `id`, `name`, `created_at`, `deleted_at`, `version` FROM `users`;
+DROP TABLE `users`;
+ALTER TABLE `users_old` RENAME TO `users`;
+CREATE INDEX `users_created_at_id_idx` ON `users` (`created_at`, `id`);
+CREATE INDEX `users_name_id_idx` ON `users` (`name`, `id`);
+CREATE INDEX `users_deleted_at_idx` ON `users` (`deleted_at`);
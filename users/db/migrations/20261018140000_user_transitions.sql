@@ -0,0 +1,16 @@
+-- migrate:up
+CREATE TABLE `user_transitions` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` TEXT NOT NULL,
+  `from_status` TEXT NOT NULL,
+  `to_status` TEXT NOT NULL,
+  `actor` TEXT NOT NULL,
+  `reason` TEXT NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL
+);
+CREATE INDEX `user_transitions_user_id_idx` ON `user_transitions` (`user_id`, `id`);
+UPDATE `users` SET `status` = 'deleted' WHERE `deleted_at` IS NOT NULL;
+
+-- migrate:down
+UPDATE `users` SET `status` = 'active' WHERE `status` = 'deleted';
+drop table user_transitions;
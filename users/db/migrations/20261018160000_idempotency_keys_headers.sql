@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE `idempotency_keys` ADD COLUMN `etag` TEXT NULL;
+ALTER TABLE `idempotency_keys` ADD COLUMN `location` TEXT NULL;
+
+-- migrate:down
+ALTER TABLE `idempotency_keys` DROP COLUMN `location`;
+ALTER TABLE `idempotency_keys` DROP COLUMN `etag`;
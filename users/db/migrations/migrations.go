@@ -0,0 +1,18 @@
+// Package migrations embeds the SQL migrations of the users schema.
+package migrations
+
+import "embed"
+
+// FS holds the migration files of SQLite databases. They use the dbmate
+// format, with the up and down statements following "-- migrate:up" and
+// "-- migrate:down" markers.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Postgres holds the migration files of PostgreSQL databases in the postgres
+// directory, in the same format. The schema is the same as the SQLite one
+// but for native uuid and timestamptz columns.
+//
+//go:embed postgres/*.sql
+var Postgres embed.FS
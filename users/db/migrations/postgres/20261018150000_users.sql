@@ -0,0 +1,33 @@
+-- migrate:up
+CREATE TABLE users (
+  id uuid PRIMARY KEY,
+  name text COLLATE "C" NOT NULL DEFAULT '',
+  given_name text NOT NULL DEFAULT '',
+  family_name text NOT NULL DEFAULT '',
+  email text NULL,
+  status text NOT NULL DEFAULT 'active',
+  version bigint NOT NULL DEFAULT 1,
+  created_at timestamptz NOT NULL,
+  updated_at timestamptz NOT NULL,
+  deleted_at timestamptz NULL
+);
+CREATE INDEX users_created_at_id_idx ON users (created_at, id);
+CREATE INDEX users_name_id_idx ON users (name, id);
+CREATE INDEX users_lower_name_idx ON users (lower(name) text_pattern_ops);
+CREATE INDEX users_deleted_at_idx ON users (deleted_at);
+CREATE UNIQUE INDEX users_email_idx ON users (lower(email));
+
+CREATE TABLE user_transitions (
+  id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users (id),
+  from_status text NOT NULL,
+  to_status text NOT NULL,
+  actor text NOT NULL,
+  reason text NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL
+);
+CREATE INDEX user_transitions_user_id_idx ON user_transitions (user_id, id);
+
+-- migrate:down
+DROP TABLE user_transitions;
+DROP TABLE users;
@@ -0,0 +1,14 @@
+-- migrate:up
+CREATE TABLE idempotency_keys (
+  key text PRIMARY KEY,
+  request_hash text NOT NULL,
+  status integer NULL,
+  content_type text NULL,
+  body bytea NULL,
+  created_at timestamptz NOT NULL,
+  expires_at timestamptz NOT NULL
+);
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- migrate:down
+DROP TABLE idempotency_keys;
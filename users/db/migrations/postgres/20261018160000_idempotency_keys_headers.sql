@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE idempotency_keys ADD COLUMN etag text NULL, ADD COLUMN location text NULL;
+
+-- migrate:down
+ALTER TABLE idempotency_keys DROP COLUMN location, DROP COLUMN etag;
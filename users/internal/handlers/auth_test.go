@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantCode      int
+		wantLevel     slog.Level
+	}{
+		{
+			name:      "failure without credentials",
+			token:     "secret",
+			wantCode:  http.StatusUnauthorized,
+			wantLevel: slog.LevelInfo,
+		},
+		{
+			name:          "failure with wrong token",
+			token:         "secret",
+			authorization: "Bearer guess",
+			wantCode:      http.StatusUnauthorized,
+			wantLevel:     slog.LevelInfo,
+		},
+		{
+			name:          "failure with another scheme",
+			token:         "secret",
+			authorization: "Basic secret",
+			wantCode:      http.StatusUnauthorized,
+			wantLevel:     slog.LevelInfo,
+		},
+		{
+			name:          "failure when no token is configured",
+			token:         "",
+			authorization: "Bearer ",
+			wantCode:      http.StatusUnauthorized,
+			wantLevel:     slog.LevelInfo,
+		},
+		{
+			name:          "success",
+			token:         "secret",
+			authorization: "Bearer secret",
+			wantCode:      http.StatusOK,
+			wantLevel:     slog.LevelDebug,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+			h := RequireToken(tt.token)(http.HandlerFunc(NewLogLevel(level).Put))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level":"debug"}`))
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantLevel, level.Level())
+			if tt.wantCode == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="admin"`, w.Header().Get("WWW-Authenticate"))
+				assert.Equal(t, `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"unauthorized","instance":"/admin/log-level","code":"unauthorized","retryable":false}`+"\n", w.Body.String())
+			}
+		})
+	}
+}
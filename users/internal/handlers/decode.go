@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/admarc/users/internal/problem"
+	"github.com/admarc/users/internal/validation"
+)
+
+// MaxBodySize limits request bodies to n bytes. Reading past the limit fails
+// with *http.MaxBytesError, which is answered with 413.
+func MaxBodySize(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decodeJSON reads exactly one JSON value from the body of r into dst,
+// rejecting unknown fields, and validates the result against its validate
+// tags.
+func decodeJSON(r *http.Request, dst any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return problem.DecodeErr(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return problem.Detailed(problem.MalformedBodyErr, "body must contain a single JSON value")
+	}
+
+	return validation.Validate(dst)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/problem"
+)
+
+// etag renders a user version as a strong entity tag.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersions returns the versions a write is conditioned on, in the
+// order of the If-Match header, or models.AnyVersion alone for "*". ok is
+// false when the request carries no If-Match header, err is set when no tag
+// of the header can match a version. Weak tags never match, If-Match uses the
+// strong comparison of RFC 9110.
+func ifMatchVersions(r *http.Request) (versions []int64, ok bool, err error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil, false, nil
+	}
+	if strings.TrimSpace(header) == "*" {
+		return []int64{models.AnyVersion}, true, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil || version <= 0 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, true, problem.PreconditionFailedErr
+	}
+
+	return versions, true, nil
+}
+
+// matchVersion calls write with each of versions until one is the current
+// version, which is how a list of tags in If-Match is matched without reading
+// the user first. write must fail with models.VersionMismatchErr, and change
+// nothing, for any other version.
+func matchVersion(versions []int64, write func(version int64) error) error {
+	var err error
+	for _, version := range versions {
+		if err = write(version); !errors.Is(err, models.VersionMismatchErr) {
+			return err
+		}
+	}
+	return err
+}
+
+// matchesVersion reports whether versions, as returned by ifMatchVersions,
+// match version.
+func matchesVersion(versions []int64, version int64) bool {
+	for _, v := range versions {
+		if v == models.AnyVersion || v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// noneMatch reports whether the If-None-Match header of r does not match tag.
+// Weak comparison is used as required by RFC 9110.
+func noneMatch(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requireIfMatch extracts the If-Match versions and writes 428 or 412 when
+// the header is missing or unusable. It returns false when the request was
+// answered.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) ([]int64, bool) {
+	versions, ok, err := ifMatchVersions(r)
+	if !ok {
+		problem.Write(w, r, problem.PreconditionRequiredErr)
+		return nil, false
+	}
+	if err != nil {
+		problem.Write(w, r, err)
+		return nil, false
+	}
+
+	return versions, true
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/internal/problem"
+)
+
+type LogLevelParams struct {
+	Level string `json:"level" validate:"required,trim,enum=debug|info|warn|error"`
+}
+
+// LogLevel reports and changes the level of the service logger at runtime.
+type LogLevel struct {
+	level *slog.LevelVar
+}
+
+func NewLogLevel(level *slog.LevelVar) LogLevel {
+	return LogLevel{level: level}
+}
+
+func (l LogLevel) Get(w http.ResponseWriter, r *http.Request) {
+	l.write(w, r)
+}
+
+func (l LogLevel) Put(w http.ResponseWriter, r *http.Request) {
+	var params LogLevelParams
+	if err := decodeJSON(r, &params); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(params.Level)); err != nil {
+		problem.Write(w, r, fmt.Errorf("failed to parse log level: %w", err))
+		return
+	}
+
+	old := l.level.Level()
+	l.level.Set(level)
+	logging.FromContext(r.Context()).WarnContext(r.Context(), "log level changed", "from", old.String(), "to", level.String())
+
+	l.write(w, r)
+}
+
+func (l LogLevel) write(w http.ResponseWriter, r *http.Request) {
+	params := LogLevelParams{Level: strings.ToLower(l.level.Level().String())}
+	if err := json.NewEncoder(w).Encode(params); err != nil {
+		problem.Write(w, r, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
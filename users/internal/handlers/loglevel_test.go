@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevel_Get(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+
+	w := httptest.NewRecorder()
+	NewLogLevel(level).Get(w, httptest.NewRequest("GET", "/admin/log-level", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"level":"warn"}`+"\n", w.Body.String())
+}
+
+func TestLogLevel_Put(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantBody  string
+		wantLevel slog.Level
+	}{
+		{
+			name:      "success",
+			body:      `{"level":"debug"}`,
+			wantCode:  http.StatusOK,
+			wantBody:  `{"level":"debug"}` + "\n",
+			wantLevel: slog.LevelDebug,
+		},
+		{
+			name:      "failure when level is unknown",
+			body:      `{"level":"verbose"}`,
+			wantCode:  http.StatusBadRequest,
+			wantBody:  `{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/admin/log-level","code":"invalid_argument","retryable":false,"errors":[{"field":"level","code":"enum","message":"must be one of debug, info, warn, error"}]}` + "\n",
+			wantLevel: slog.LevelInfo,
+		},
+		{
+			name:      "failure when body is malformed",
+			body:      `{"level":`,
+			wantCode:  http.StatusBadRequest,
+			wantBody:  `{"type":"about:blank","title":"Bad Request","status":400,"detail":"body contains malformed JSON","instance":"/admin/log-level","code":"malformed_body","retryable":false}` + "\n",
+			wantLevel: slog.LevelInfo,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+
+			w := httptest.NewRecorder()
+			NewLogLevel(level).Put(w, httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+			assert.Equal(t, tt.wantLevel, level.Level())
+		})
+	}
+}
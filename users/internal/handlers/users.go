@@ -3,21 +3,71 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/problem"
+	"github.com/admarc/users/internal/validation"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/go-chi/chi"
 )
 
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// ActorHeader names who is making a request. It is recorded with every status
+// change of a user. The service doesn't authenticate it: it is whatever the
+// client sends, so the recorded actor can only be trusted when a gateway in
+// front of the service authenticates callers and sets the header itself.
+const ActorHeader = "X-Actor"
+
+const anonymousActor = "anonymous"
+
 type CreateUserParams struct {
-	Name string
+	Name       string `json:"name" validate:"required,normalize=nfc,trim,max=100"`
+	GivenName  string `json:"given_name" validate:"normalize=nfc,trim,max=100"`
+	FamilyName string `json:"family_name" validate:"normalize=nfc,trim,max=100"`
+	Email      string `json:"email" validate:"required,trim,max=254"`
+	Status     string `json:"status" validate:"enum=pending|active"`
+}
+
+// UpdateUserParams is the payload of PUT and the result of PATCH. Email may
+// be empty for users that have none, the service checks that.
+type UpdateUserParams struct {
+	Name       string `json:"name" validate:"required,normalize=nfc,trim,max=100"`
+	GivenName  string `json:"given_name" validate:"normalize=nfc,trim,max=100"`
+	FamilyName string `json:"family_name" validate:"normalize=nfc,trim,max=100"`
+	Email      string `json:"email" validate:"trim,max=254"`
+}
+
+// StatusChangeParams is the optional payload of the status change endpoints.
+type StatusChangeParams struct {
+	Reason string `json:"reason" validate:"normalize=nfc,trim,max=500"`
+}
+
+func (p UpdateUserParams) user(id string) models.User {
+	return models.User{ID: id, Name: p.Name, GivenName: p.GivenName, FamilyName: p.FamilyName, Email: p.Email}
 }
 
 //go:generate moq -rm -out users_mock.go . UsersService
 type UsersService interface {
-	Create(ctx context.Context, name string) (models.User, error)
+	Create(ctx context.Context, usr models.User) (models.User, error)
 	Get(ctx context.Context, id string) (models.User, error)
+	List(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error)
+	Update(ctx context.Context, usr models.User) (models.User, error)
+	Delete(ctx context.Context, id string, version int64, change models.StatusChange) error
+	Restore(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+	Activate(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+	Suspend(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+	Lock(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+	Transitions(ctx context.Context, id string) ([]models.UserTransition, error)
 }
 
 type Users struct {
@@ -32,27 +82,32 @@ func (u Users) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var userParams CreateUserParams
-	if err := json.NewDecoder(r.Body).Decode(&userParams); err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+	if err := decodeJSON(r, &userParams); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	user, err := u.user.Create(ctx, userParams.Name)
+	user, err := u.user.Create(ctx, models.User{
+		Name:       userParams.Name,
+		GivenName:  userParams.GivenName,
+		FamilyName: userParams.FamilyName,
+		Email:      userParams.Email,
+		Status:     models.UserStatus(userParams.Status),
+	})
 	if err != nil {
-		if errors.Is(err, models.UserCreateParamInvalidNameErr) {
-			http.Error(w, "", http.StatusBadRequest)
-			return
-		}
-		http.Error(w, "", http.StatusInternalServerError)
+		problem.Write(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag(user.Version))
 	if err := json.NewEncoder(w).Encode(user); err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+		problem.Write(w, r, fmt.Errorf("failed to encode response: %w", err))
 		return
 	}
 }
 
+// Get returns the user with its version as ETag and answers 304 Not Modified
+// when the version matches If-None-Match.
 func (u Users) Get(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -60,12 +115,317 @@ func (u Users) Get(w http.ResponseWriter, r *http.Request) {
 
 	user, err := u.user.Get(ctx, id)
 	if err != nil {
-		http.Error(w, "", http.StatusNotFound)
+		problem.Write(w, r, err)
+		return
+	}
+
+	tag := etag(user.Version)
+	w.Header().Set("ETag", tag)
+	if !noneMatch(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		problem.Write(w, r, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+// List pages through users. Query parameters:
+//
+//	limit            page size
+//	cursor           next_cursor from the previous page
+//	name_prefix      only users whose name starts with the value
+//	sort             created_at or name, prefixed with "-" for descending order
+//	include_deleted  also return soft-deleted users
+func (u Users) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	params := models.ListUsersParams{
+		NamePrefix: query.Get("name_prefix"),
+		Cursor:     query.Get("cursor"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			problem.Write(w, r, models.InvalidFieldErr(models.ListUsersParamInvalidLimitErr, "limit", "not_integer", "limit must be an integer"))
+			return
+		}
+		params.Limit = l
+	}
+
+	if includeDeleted := query.Get("include_deleted"); includeDeleted != "" {
+		b, err := strconv.ParseBool(includeDeleted)
+		if err != nil {
+			problem.Write(w, r, models.InvalidFieldErr(models.InvalidErr, "include_deleted", "not_boolean", "include_deleted must be true or false"))
+			return
+		}
+		params.IncludeDeleted = b
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		params.Desc = strings.HasPrefix(sort, "-")
+		params.SortBy = models.UsersSortField(strings.TrimPrefix(sort, "-"))
+	}
+
+	page, err := u.user.List(ctx, params)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		problem.Write(w, r, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+// Update replaces the user with the payload of a PUT request. The request
+// must carry the current version in If-Match.
+func (u Users) Update(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	versions, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var userParams UpdateUserParams
+	if err := decodeJSON(r, &userParams); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	u.update(w, r, userParams.user(id), versions)
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902),
+// selected by the Content-Type header, to the current representation of the
+// user and stores the result. The request must carry the current version in
+// If-Match.
+func (u Users) Patch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := chi.URLParam(r, "id")
+
+	versions, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != mergePatchContentType && contentType != jsonPatchContentType) {
+		w.Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		problem.Write(w, r, problem.UnsupportedMediaTypeErr)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, r, problem.DecodeErr(err))
+		return
+	}
+
+	user, err := u.user.Get(ctx, id)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	if !matchesVersion(versions, user.Version) {
+		problem.Write(w, r, models.VersionMismatchErr)
+		return
+	}
+
+	original, err := json.Marshal(user)
+	if err != nil {
+		problem.Write(w, r, fmt.Errorf("failed to marshal user: %w", err))
+		return
+	}
+
+	var patched []byte
+	switch contentType {
+	case mergePatchContentType:
+		patched, err = jsonpatch.MergePatch(original, body)
+		if err != nil {
+			problem.Write(w, r, problem.Detailed(problem.MalformedBodyErr, "failed to apply merge patch: "+err.Error()))
+			return
+		}
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			problem.Write(w, r, problem.Detailed(problem.MalformedBodyErr, "failed to decode json patch: "+err.Error()))
+			return
+		}
+		patched, err = patch.Apply(original)
+		if err != nil {
+			problem.Write(w, r, problem.Detailed(problem.UnprocessableErr, "failed to apply json patch: "+err.Error()))
+			return
+		}
+	}
+
+	var patchedUser models.User
+	if err := json.Unmarshal(patched, &patchedUser); err != nil {
+		problem.Write(w, r, problem.Detailed(problem.MalformedBodyErr, "failed to decode patched user: "+err.Error()))
+		return
+	}
+
+	if patchedUser.ID != user.ID {
+		problem.Write(w, r, models.InvalidFieldErr(models.UserUpdateParamImmutableIDErr, "id", "immutable", "id can not be changed"))
+		return
+	}
+
+	// The patched document goes through the same rules as a PUT payload.
+	userParams := UpdateUserParams{
+		Name:       patchedUser.Name,
+		GivenName:  patchedUser.GivenName,
+		FamilyName: patchedUser.FamilyName,
+		Email:      patchedUser.Email,
+	}
+	if err := validation.Validate(&userParams); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	// The patch was computed from this version, the write must not apply it
+	// to any other.
+	u.update(w, r, userParams.user(patchedUser.ID), []int64{user.Version})
+}
+
+// Delete soft deletes the user. The request must carry the current version
+// in If-Match.
+func (u Users) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := chi.URLParam(r, "id")
+
+	versions, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	err := matchVersion(versions, func(version int64) error {
+		return u.user.Delete(ctx, id, version, models.StatusChange{Actor: actor(r)})
+	})
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Restore brings back a soft-deleted user. The request must carry the
+// version of the deleted user in If-Match.
+func (u Users) Restore(w http.ResponseWriter, r *http.Request) {
+	u.changeStatus(w, r, u.user.Restore)
+}
+
+// Activate moves the user to active. The request must carry the current
+// version in If-Match and may give a reason.
+func (u Users) Activate(w http.ResponseWriter, r *http.Request) {
+	u.changeStatus(w, r, u.user.Activate)
+}
+
+// Suspend moves the user to suspended. The request must carry the current
+// version in If-Match and may give a reason.
+func (u Users) Suspend(w http.ResponseWriter, r *http.Request) {
+	u.changeStatus(w, r, u.user.Suspend)
+}
+
+// Lock moves the user to locked. The request must carry the current version
+// in If-Match and may give a reason.
+func (u Users) Lock(w http.ResponseWriter, r *http.Request) {
+	u.changeStatus(w, r, u.user.Lock)
+}
+
+// Transitions lists the status changes of the user, oldest first.
+func (u Users) Transitions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := chi.URLParam(r, "id")
+
+	ts, err := u.user.Transitions(ctx, id)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	if ts == nil {
+		ts = []models.UserTransition{}
+	}
+	if err := json.NewEncoder(w).Encode(struct {
+		Transitions []models.UserTransition `json:"transitions"`
+	}{ts}); err != nil {
+		problem.Write(w, r, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+type statusChangeFunc func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+
+func (u Users) changeStatus(w http.ResponseWriter, r *http.Request, change statusChangeFunc) {
+	ctx := r.Context()
+
+	id := chi.URLParam(r, "id")
+
+	versions, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var params StatusChangeParams
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &params); err != nil {
+			problem.Write(w, r, err)
+			return
+		}
+	}
+
+	var user models.User
+	err := matchVersion(versions, func(version int64) (err error) {
+		user, err = change(ctx, id, version, models.StatusChange{Actor: actor(r), Reason: params.Reason})
+		return err
+	})
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(user.Version))
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		problem.Write(w, r, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+// update stores usr at the first of versions that is current.
+func (u Users) update(w http.ResponseWriter, r *http.Request, usr models.User, versions []int64) {
+	var user models.User
+	err := matchVersion(versions, func(version int64) (err error) {
+		usr.Version = version
+		user, err = u.user.Update(r.Context(), usr)
+		return err
+	})
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", etag(user.Version))
 	if err := json.NewEncoder(w).Encode(user); err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+		problem.Write(w, r, fmt.Errorf("failed to encode response: %w", err))
 		return
 	}
 }
+
+// actor returns who the request claims to be made by, see ActorHeader.
+func actor(r *http.Request) string {
+	if a := strings.TrimSpace(r.Header.Get(ActorHeader)); a != "" {
+		return a
+	}
+	return anonymousActor
+}
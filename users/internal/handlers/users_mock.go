@@ -19,12 +19,36 @@ var _ UsersService = &UsersServiceMock{}
 //
 //		// make and configure a mocked UsersService
 //		mockedUsersService := &UsersServiceMock{
-//			CreateFunc: func(ctx context.Context, name string) (models.User, error) {
+//			ActivateFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+//				panic("mock out the Activate method")
+//			},
+//			CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
 //				panic("mock out the Create method")
 //			},
-//			GetFunc: func(ctx context.Context, name string) (models.User, error) {
+//			DeleteFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) error {
+//				panic("mock out the Delete method")
+//			},
+//			GetFunc: func(ctx context.Context, id string) (models.User, error) {
 //				panic("mock out the Get method")
 //			},
+//			ListFunc: func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+//				panic("mock out the List method")
+//			},
+//			LockFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+//				panic("mock out the Lock method")
+//			},
+//			RestoreFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+//				panic("mock out the Restore method")
+//			},
+//			SuspendFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+//				panic("mock out the Suspend method")
+//			},
+//			TransitionsFunc: func(ctx context.Context, id string) ([]models.UserTransition, error) {
+//				panic("mock out the Transitions method")
+//			},
+//			UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+//				panic("mock out the Update method")
+//			},
 //		}
 //
 //		// use mockedUsersService in code that requires UsersService
@@ -32,49 +56,201 @@ var _ UsersService = &UsersServiceMock{}
 //
 //	}
 type UsersServiceMock struct {
+	// ActivateFunc mocks the Activate method.
+	ActivateFunc func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+
 	// CreateFunc mocks the Create method.
-	CreateFunc func(ctx context.Context, name string) (models.User, error)
+	CreateFunc func(ctx context.Context, usr models.User) (models.User, error)
+
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(ctx context.Context, id string, version int64, change models.StatusChange) error
 
 	// GetFunc mocks the Get method.
-	GetFunc func(ctx context.Context, name string) (models.User, error)
+	GetFunc func(ctx context.Context, id string) (models.User, error)
+
+	// ListFunc mocks the List method.
+	ListFunc func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error)
+
+	// LockFunc mocks the Lock method.
+	LockFunc func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+
+	// RestoreFunc mocks the Restore method.
+	RestoreFunc func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+
+	// SuspendFunc mocks the Suspend method.
+	SuspendFunc func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+
+	// TransitionsFunc mocks the Transitions method.
+	TransitionsFunc func(ctx context.Context, id string) ([]models.UserTransition, error)
+
+	// UpdateFunc mocks the Update method.
+	UpdateFunc func(ctx context.Context, usr models.User) (models.User, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// Activate holds details about calls to the Activate method.
+		Activate []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Version is the version argument value.
+			Version int64
+			// Change is the change argument value.
+			Change models.StatusChange
+		}
 		// Create holds details about calls to the Create method.
 		Create []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
-			// Name is the name argument value.
-			Name string
+			// Usr is the usr argument value.
+			Usr models.User
+		}
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Version is the version argument value.
+			Version int64
+			// Change is the change argument value.
+			Change models.StatusChange
 		}
 		// Get holds details about calls to the Get method.
 		Get []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
-			// Name is the name argument value.
-			Name string
+			// ID is the id argument value.
+			ID string
+		}
+		// List holds details about calls to the List method.
+		List []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Params is the params argument value.
+			Params models.ListUsersParams
+		}
+		// Lock holds details about calls to the Lock method.
+		Lock []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Version is the version argument value.
+			Version int64
+			// Change is the change argument value.
+			Change models.StatusChange
+		}
+		// Restore holds details about calls to the Restore method.
+		Restore []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Version is the version argument value.
+			Version int64
+			// Change is the change argument value.
+			Change models.StatusChange
+		}
+		// Suspend holds details about calls to the Suspend method.
+		Suspend []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Version is the version argument value.
+			Version int64
+			// Change is the change argument value.
+			Change models.StatusChange
+		}
+		// Transitions holds details about calls to the Transitions method.
+		Transitions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// Update holds details about calls to the Update method.
+		Update []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Usr is the usr argument value.
+			Usr models.User
 		}
 	}
-	lockCreate sync.RWMutex
-	lockGet    sync.RWMutex
+	lockActivate    sync.RWMutex
+	lockCreate      sync.RWMutex
+	lockDelete      sync.RWMutex
+	lockGet         sync.RWMutex
+	lockList        sync.RWMutex
+	lockLock        sync.RWMutex
+	lockRestore     sync.RWMutex
+	lockSuspend     sync.RWMutex
+	lockTransitions sync.RWMutex
+	lockUpdate      sync.RWMutex
+}
+
+// Activate calls ActivateFunc.
+func (mock *UsersServiceMock) Activate(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	if mock.ActivateFunc == nil {
+		panic("UsersServiceMock.ActivateFunc: method is nil but UsersService.Activate was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}{
+		Ctx:     ctx,
+		ID:      id,
+		Version: version,
+		Change:  change,
+	}
+	mock.lockActivate.Lock()
+	mock.calls.Activate = append(mock.calls.Activate, callInfo)
+	mock.lockActivate.Unlock()
+	return mock.ActivateFunc(ctx, id, version, change)
+}
+
+// ActivateCalls gets all the calls that were made to Activate.
+// Check the length with:
+//
+//	len(mockedUsersService.ActivateCalls())
+func (mock *UsersServiceMock) ActivateCalls() []struct {
+	Ctx     context.Context
+	ID      string
+	Version int64
+	Change  models.StatusChange
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}
+	mock.lockActivate.RLock()
+	calls = mock.calls.Activate
+	mock.lockActivate.RUnlock()
+	return calls
 }
 
 // Create calls CreateFunc.
-func (mock *UsersServiceMock) Create(ctx context.Context, name string) (models.User, error) {
+func (mock *UsersServiceMock) Create(ctx context.Context, usr models.User) (models.User, error) {
 	if mock.CreateFunc == nil {
 		panic("UsersServiceMock.CreateFunc: method is nil but UsersService.Create was just called")
 	}
 	callInfo := struct {
-		Ctx  context.Context
-		Name string
+		Ctx context.Context
+		Usr models.User
 	}{
-		Ctx:  ctx,
-		Name: name,
+		Ctx: ctx,
+		Usr: usr,
 	}
 	mock.lockCreate.Lock()
 	mock.calls.Create = append(mock.calls.Create, callInfo)
 	mock.lockCreate.Unlock()
-	return mock.CreateFunc(ctx, name)
+	return mock.CreateFunc(ctx, usr)
 }
 
 // CreateCalls gets all the calls that were made to Create.
@@ -82,12 +258,12 @@ func (mock *UsersServiceMock) Create(ctx context.Context, name string) (models.U
 //
 //	len(mockedUsersService.CreateCalls())
 func (mock *UsersServiceMock) CreateCalls() []struct {
-	Ctx  context.Context
-	Name string
+	Ctx context.Context
+	Usr models.User
 } {
 	var calls []struct {
-		Ctx  context.Context
-		Name string
+		Ctx context.Context
+		Usr models.User
 	}
 	mock.lockCreate.RLock()
 	calls = mock.calls.Create
@@ -95,22 +271,66 @@ func (mock *UsersServiceMock) CreateCalls() []struct {
 	return calls
 }
 
+// Delete calls DeleteFunc.
+func (mock *UsersServiceMock) Delete(ctx context.Context, id string, version int64, change models.StatusChange) error {
+	if mock.DeleteFunc == nil {
+		panic("UsersServiceMock.DeleteFunc: method is nil but UsersService.Delete was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}{
+		Ctx:     ctx,
+		ID:      id,
+		Version: version,
+		Change:  change,
+	}
+	mock.lockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	mock.lockDelete.Unlock()
+	return mock.DeleteFunc(ctx, id, version, change)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//
+//	len(mockedUsersService.DeleteCalls())
+func (mock *UsersServiceMock) DeleteCalls() []struct {
+	Ctx     context.Context
+	ID      string
+	Version int64
+	Change  models.StatusChange
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}
+	mock.lockDelete.RLock()
+	calls = mock.calls.Delete
+	mock.lockDelete.RUnlock()
+	return calls
+}
+
 // Get calls GetFunc.
-func (mock *UsersServiceMock) Get(ctx context.Context, name string) (models.User, error) {
+func (mock *UsersServiceMock) Get(ctx context.Context, id string) (models.User, error) {
 	if mock.GetFunc == nil {
 		panic("UsersServiceMock.GetFunc: method is nil but UsersService.Get was just called")
 	}
 	callInfo := struct {
-		Ctx  context.Context
-		Name string
+		Ctx context.Context
+		ID  string
 	}{
-		Ctx:  ctx,
-		Name: name,
+		Ctx: ctx,
+		ID:  id,
 	}
 	mock.lockGet.Lock()
 	mock.calls.Get = append(mock.calls.Get, callInfo)
 	mock.lockGet.Unlock()
-	return mock.GetFunc(ctx, name)
+	return mock.GetFunc(ctx, id)
 }
 
 // GetCalls gets all the calls that were made to Get.
@@ -118,15 +338,255 @@ func (mock *UsersServiceMock) Get(ctx context.Context, name string) (models.User
 //
 //	len(mockedUsersService.GetCalls())
 func (mock *UsersServiceMock) GetCalls() []struct {
-	Ctx  context.Context
-	Name string
+	Ctx context.Context
+	ID  string
 } {
 	var calls []struct {
-		Ctx  context.Context
-		Name string
+		Ctx context.Context
+		ID  string
 	}
 	mock.lockGet.RLock()
 	calls = mock.calls.Get
 	mock.lockGet.RUnlock()
 	return calls
 }
+
+// List calls ListFunc.
+func (mock *UsersServiceMock) List(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+	if mock.ListFunc == nil {
+		panic("UsersServiceMock.ListFunc: method is nil but UsersService.List was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Params models.ListUsersParams
+	}{
+		Ctx:    ctx,
+		Params: params,
+	}
+	mock.lockList.Lock()
+	mock.calls.List = append(mock.calls.List, callInfo)
+	mock.lockList.Unlock()
+	return mock.ListFunc(ctx, params)
+}
+
+// ListCalls gets all the calls that were made to List.
+// Check the length with:
+//
+//	len(mockedUsersService.ListCalls())
+func (mock *UsersServiceMock) ListCalls() []struct {
+	Ctx    context.Context
+	Params models.ListUsersParams
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Params models.ListUsersParams
+	}
+	mock.lockList.RLock()
+	calls = mock.calls.List
+	mock.lockList.RUnlock()
+	return calls
+}
+
+// Lock calls LockFunc.
+func (mock *UsersServiceMock) Lock(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	if mock.LockFunc == nil {
+		panic("UsersServiceMock.LockFunc: method is nil but UsersService.Lock was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}{
+		Ctx:     ctx,
+		ID:      id,
+		Version: version,
+		Change:  change,
+	}
+	mock.lockLock.Lock()
+	mock.calls.Lock = append(mock.calls.Lock, callInfo)
+	mock.lockLock.Unlock()
+	return mock.LockFunc(ctx, id, version, change)
+}
+
+// LockCalls gets all the calls that were made to Lock.
+// Check the length with:
+//
+//	len(mockedUsersService.LockCalls())
+func (mock *UsersServiceMock) LockCalls() []struct {
+	Ctx     context.Context
+	ID      string
+	Version int64
+	Change  models.StatusChange
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}
+	mock.lockLock.RLock()
+	calls = mock.calls.Lock
+	mock.lockLock.RUnlock()
+	return calls
+}
+
+// Restore calls RestoreFunc.
+func (mock *UsersServiceMock) Restore(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	if mock.RestoreFunc == nil {
+		panic("UsersServiceMock.RestoreFunc: method is nil but UsersService.Restore was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}{
+		Ctx:     ctx,
+		ID:      id,
+		Version: version,
+		Change:  change,
+	}
+	mock.lockRestore.Lock()
+	mock.calls.Restore = append(mock.calls.Restore, callInfo)
+	mock.lockRestore.Unlock()
+	return mock.RestoreFunc(ctx, id, version, change)
+}
+
+// RestoreCalls gets all the calls that were made to Restore.
+// Check the length with:
+//
+//	len(mockedUsersService.RestoreCalls())
+func (mock *UsersServiceMock) RestoreCalls() []struct {
+	Ctx     context.Context
+	ID      string
+	Version int64
+	Change  models.StatusChange
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}
+	mock.lockRestore.RLock()
+	calls = mock.calls.Restore
+	mock.lockRestore.RUnlock()
+	return calls
+}
+
+// Suspend calls SuspendFunc.
+func (mock *UsersServiceMock) Suspend(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	if mock.SuspendFunc == nil {
+		panic("UsersServiceMock.SuspendFunc: method is nil but UsersService.Suspend was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}{
+		Ctx:     ctx,
+		ID:      id,
+		Version: version,
+		Change:  change,
+	}
+	mock.lockSuspend.Lock()
+	mock.calls.Suspend = append(mock.calls.Suspend, callInfo)
+	mock.lockSuspend.Unlock()
+	return mock.SuspendFunc(ctx, id, version, change)
+}
+
+// SuspendCalls gets all the calls that were made to Suspend.
+// Check the length with:
+//
+//	len(mockedUsersService.SuspendCalls())
+func (mock *UsersServiceMock) SuspendCalls() []struct {
+	Ctx     context.Context
+	ID      string
+	Version int64
+	Change  models.StatusChange
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}
+	mock.lockSuspend.RLock()
+	calls = mock.calls.Suspend
+	mock.lockSuspend.RUnlock()
+	return calls
+}
+
+// Transitions calls TransitionsFunc.
+func (mock *UsersServiceMock) Transitions(ctx context.Context, id string) ([]models.UserTransition, error) {
+	if mock.TransitionsFunc == nil {
+		panic("UsersServiceMock.TransitionsFunc: method is nil but UsersService.Transitions was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockTransitions.Lock()
+	mock.calls.Transitions = append(mock.calls.Transitions, callInfo)
+	mock.lockTransitions.Unlock()
+	return mock.TransitionsFunc(ctx, id)
+}
+
+// TransitionsCalls gets all the calls that were made to Transitions.
+// Check the length with:
+//
+//	len(mockedUsersService.TransitionsCalls())
+func (mock *UsersServiceMock) TransitionsCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockTransitions.RLock()
+	calls = mock.calls.Transitions
+	mock.lockTransitions.RUnlock()
+	return calls
+}
+
+// Update calls UpdateFunc.
+func (mock *UsersServiceMock) Update(ctx context.Context, usr models.User) (models.User, error) {
+	if mock.UpdateFunc == nil {
+		panic("UsersServiceMock.UpdateFunc: method is nil but UsersService.Update was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Usr models.User
+	}{
+		Ctx: ctx,
+		Usr: usr,
+	}
+	mock.lockUpdate.Lock()
+	mock.calls.Update = append(mock.calls.Update, callInfo)
+	mock.lockUpdate.Unlock()
+	return mock.UpdateFunc(ctx, usr)
+}
+
+// UpdateCalls gets all the calls that were made to Update.
+// Check the length with:
+//
+//	len(mockedUsersService.UpdateCalls())
+func (mock *UsersServiceMock) UpdateCalls() []struct {
+	Ctx context.Context
+	Usr models.User
+} {
+	var calls []struct {
+		Ctx context.Context
+		Usr models.User
+	}
+	mock.lockUpdate.RLock()
+	calls = mock.calls.Update
+	mock.lockUpdate.RUnlock()
+	return calls
+}
@@ -3,15 +3,23 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/admarc/users/internal/models"
 	"github.com/stretchr/testify/assert"
 )
 
+// withIfMatch sets the If-Match header of r to tag.
+func withIfMatch(r *http.Request, tag string) *http.Request {
+	r.Header.Set("If-Match", tag)
+	return r
+}
+
 func TestUsers_Create(t *testing.T) {
 	type fields struct {
 		user UsersService
@@ -36,59 +44,89 @@ func TestUsers_Create(t *testing.T) {
 				w: httptest.NewRecorder(),
 				r: httptest.NewRequest("GET", "/", strings.NewReader(`bad payload`)),
 			},
-			wantCode: http.StatusInternalServerError,
-			wantBody: []byte("\n"),
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"body contains malformed JSON at offset 1","instance":"/","code":"malformed_body","retryable":false}` + "\n"),
 		},
 		{
 			name: "failure when service fails with invalid name",
 			fields: fields{
 				user: &UsersServiceMock{
-					CreateFunc: func(ctx context.Context, name string) (models.User, error) {
-						assert.Equal(t, "mike", name)
+					CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, "mike", usr.Name)
 						return models.User{}, models.UserCreateParamInvalidNameErr
 					},
 				},
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": "mike"}`)),
+				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": "mike", "email": "tod@example.com"}`)),
 			},
 			wantCode: http.StatusBadRequest,
-			wantBody: []byte("\n"),
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid argument","instance":"/","code":"invalid_argument","retryable":false}` + "\n"),
 		},
 		{
 			name: "failure when service fails with unknown error",
 			fields: fields{
 				user: &UsersServiceMock{
-					CreateFunc: func(ctx context.Context, name string) (models.User, error) {
-						assert.Equal(t, "mike", name)
+					CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, "mike", usr.Name)
 						return models.User{}, errors.New("unknown error")
 					},
 				},
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": "mike"}`)),
+				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": "mike", "email": "tod@example.com"}`)),
 			},
 			wantCode: http.StatusInternalServerError,
-			wantBody: []byte("\n"),
+			wantBody: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/","code":"internal_server_error","retryable":false}` + "\n"),
 		},
 		{
 			name: "success",
 			fields: fields{
 				user: &UsersServiceMock{
-					CreateFunc: func(ctx context.Context, name string) (models.User, error) {
-						assert.Equal(t, "mike", name)
-						return models.User{Name: name, ID: "1"}, nil
+					CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{Name: "mike", GivenName: "Mike", Email: "Mike@example.com", Status: models.UserStatusPending}, usr)
+						usr.ID = "1"
+						usr.Email = "mike@example.com"
+						return usr, nil
 					},
 				},
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": "mike"}`)),
+				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": " mike ", "given_name": "Mike", "email": "Mike@example.com", "status": "pending"}`)),
 			},
 			wantCode: http.StatusOK,
-			wantBody: []byte(`{"id":"1","name":"mike"}` + "\n"),
+			wantBody: []byte(`{"id":"1","name":"mike","given_name":"Mike","email":"mike@example.com","status":"pending","version":0,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "failure when email is taken",
+			fields: fields{
+				user: &UsersServiceMock{
+					CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						return models.User{}, fmt.Errorf("failed to create user: %w", models.UserEmailTakenErr)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": "mike", "email": "tod@example.com"}`)),
+			},
+			wantCode: http.StatusConflict,
+			wantBody: []byte(`{"type":"about:blank","title":"Conflict","status":409,"detail":"email already in use","instance":"/","code":"email_taken","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when status is not allowed",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/", strings.NewReader(`{"name": "mike", "email": "tod@example.com", "status": "suspended"}`)),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/","code":"invalid_argument","retryable":false,"errors":[{"field":"status","code":"enum","message":"must be one of pending, active"}]}` + "\n"),
 		},
 	}
 	for _, tt := range tests {
@@ -99,6 +137,9 @@ func TestUsers_Create(t *testing.T) {
 			u.Create(tt.args.w, tt.args.r)
 			assert.Equal(t, tt.wantCode, tt.args.w.Code)
 			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
 		})
 	}
 }
@@ -123,7 +164,7 @@ func TestUsers_Get(t *testing.T) {
 			fields: fields{
 				user: &UsersServiceMock{
 					GetFunc: func(ctx context.Context, id string) (models.User, error) {
-						return models.User{}, errors.New("User not found")
+						return models.User{}, fmt.Errorf("failed to get user: %w", models.NotFoundErr)
 					},
 				},
 			},
@@ -132,7 +173,55 @@ func TestUsers_Get(t *testing.T) {
 				r: httptest.NewRequest("GET", "/uuid", nil),
 			},
 			wantCode: http.StatusNotFound,
-			wantBody: []byte("\n"),
+			wantBody: []byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/uuid","code":"not_found","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when database fails",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{}, errors.New("database is locked")
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/uuid", nil),
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/uuid","code":"internal_server_error","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when client cancels the request",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{}, fmt.Errorf("failed to get user: %w", context.Canceled)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/uuid", nil),
+			},
+			wantCode: 499,
+			wantBody: []byte(`{"type":"about:blank","title":"Client Closed Request","status":499,"detail":"context canceled","instance":"/uuid","code":"client_closed_request","retryable":true}` + "\n"),
+		},
+		{
+			name: "failure when request times out",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{}, fmt.Errorf("failed to get user: %w", context.DeadlineExceeded)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/uuid", nil),
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: []byte(`{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"context deadline exceeded","instance":"/uuid","code":"timeout","retryable":true}` + "\n"),
 		},
 		{
 			name: "success",
@@ -148,7 +237,27 @@ func TestUsers_Get(t *testing.T) {
 				r: httptest.NewRequest("GET", "/uuid", nil),
 			},
 			wantCode: http.StatusOK,
-			wantBody: []byte(`{"id":"383673b8-bd9a-41b4-adba-79bc1abc889e","name":"tod"}` + "\n"),
+			wantBody: []byte(`{"id":"383673b8-bd9a-41b4-adba-79bc1abc889e","name":"tod","status":"","version":0,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "not modified when version matches If-None-Match",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "tod", Version: 3}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: func() *http.Request {
+					r := httptest.NewRequest("GET", "/uuid", nil)
+					r.Header.Set("If-None-Match", `"2", W/"3"`)
+					return r
+				}(),
+			},
+			wantCode: http.StatusNotModified,
+			wantBody: nil,
 		},
 	}
 	for _, tt := range tests {
@@ -158,7 +267,966 @@ func TestUsers_Get(t *testing.T) {
 			}
 			u.Get(tt.args.w, tt.args.r)
 			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			if tt.args.w.Code < http.StatusBadRequest {
+				assert.NotEmpty(t, tt.args.w.Header().Get("ETag"))
+			}
+			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestUsers_List(t *testing.T) {
+	createdAt := time.Date(2022, 12, 2, 13, 26, 35, 0, time.UTC)
+
+	type fields struct {
+		user UsersService
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantCode int
+		wantBody []byte
+	}{
+		{
+			name: "failure when limit is not a number",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/?limit=ten", nil),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/","code":"invalid_argument","retryable":false,"errors":[{"field":"limit","code":"not_integer","message":"limit must be an integer"}]}` + "\n"),
+		},
+		{
+			name: "failure when service rejects params",
+			fields: fields{
+				user: &UsersServiceMock{
+					ListFunc: func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+						return models.UsersPage{}, models.InvalidCursorErr
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/?cursor=bogus", nil),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid cursor: invalid argument","instance":"/","code":"invalid_cursor","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when service fails with unknown error",
+			fields: fields{
+				user: &UsersServiceMock{
+					ListFunc: func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+						return models.UsersPage{}, errors.New("unknown error")
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/", nil),
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/","code":"internal_server_error","retryable":false}` + "\n"),
+		},
+		{
+			name: "success",
+			fields: fields{
+				user: &UsersServiceMock{
+					ListFunc: func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+						assert.Equal(t, models.ListUsersParams{
+							NamePrefix: "to",
+							SortBy:     models.UsersSortName,
+							Desc:       true,
+							Limit:      1,
+							Cursor:     "abc",
+						}, params)
+						return models.UsersPage{
+							Users:      []models.User{{ID: "1", Name: "tod", CreatedAt: createdAt}},
+							NextCursor: "def",
+						}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/?name_prefix=to&sort=-name&limit=1&cursor=abc", nil),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"users":[{"id":"1","name":"tod","status":"","version":0,"created_at":"2022-12-02T13:26:35Z","updated_at":"0001-01-01T00:00:00Z"}],"next_cursor":"def"}` + "\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{
+				user: tt.fields.user,
+			}
+			u.List(tt.args.w, tt.args.r)
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
 			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestUsers_Update(t *testing.T) {
+	type fields struct {
+		user UsersService
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantCode int
+		wantBody []byte
+	}{
+		{
+			name: "failure when If-Match is missing",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com"}`)),
+			},
+			wantCode: http.StatusPreconditionRequired,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Required","status":428,"detail":"precondition required","instance":"/uuid","code":"precondition_required","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when If-Match is not a version",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com"}`)), `W/"1"`),
+			},
+			wantCode: http.StatusPreconditionFailed,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Failed","status":412,"detail":"precondition failed","instance":"/uuid","code":"precondition_failed","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when version is stale",
+			fields: fields{
+				user: &UsersServiceMock{
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						return models.User{}, models.VersionMismatchErr
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com"}`)), `"1"`),
+			},
+			wantCode: http.StatusPreconditionFailed,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Failed","status":412,"detail":"version mismatch","instance":"/uuid","code":"version_mismatch","retryable":false}` + "\n"),
+		},
+		{
+			name: "success when If-Match is *",
+			fields: fields{
+				user: &UsersServiceMock{
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.AnyVersion, usr.Version)
+						usr.Version = 3
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com"}`)), `*`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"","name":"mike","email":"mike@example.com","status":"","version":3,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "failure when payload can't be decoded",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`bad payload`)), `"1"`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"body contains malformed JSON at offset 1","instance":"/uuid","code":"malformed_body","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when service fails with invalid name",
+			fields: fields{
+				user: &UsersServiceMock{
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						return models.User{}, models.UserUpdateParamInvalidNameErr
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com"}`)), `"1"`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid argument","instance":"/uuid","code":"invalid_argument","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when payload fails validation",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "  "}`)), `"1"`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/uuid","code":"invalid_argument","retryable":false,"errors":[{"field":"name","code":"required","message":"value is required"}]}` + "\n"),
+		},
+		{
+			name: "failure when payload has unknown fields",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com", "admin": true}`)), `"1"`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/uuid","code":"invalid_argument","retryable":false,"errors":[{"field":"admin","code":"unknown","message":"field is not allowed"}]}` + "\n"),
+		},
+		{
+			name: "failure when payload has trailing data",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com"}{}`)), `"1"`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"body must contain a single JSON value","instance":"/uuid","code":"malformed_body","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when payload is too large",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: func() *http.Request {
+					r := withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "`+strings.Repeat("a", 64)+`"}`)), `"1"`)
+					r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 32)
+					return r
+				}(),
+			},
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: []byte(`{"type":"about:blank","title":"Request Entity Too Large","status":413,"detail":"body must not be larger than 32 bytes","instance":"/uuid","code":"payload_too_large","retryable":false}` + "\n"),
+		},
+		{
+			name: "success",
+			fields: fields{
+				user: &UsersServiceMock{
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{Name: "mike", Email: "mike@example.com", Version: 1}, usr)
+						return models.User{ID: "1", Name: usr.Name, Email: usr.Email, Status: models.UserStatusActive, Version: 2}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("PUT", "/uuid", strings.NewReader(`{"name": "mike", "email": "mike@example.com"}`)), `"1"`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"mike","email":"mike@example.com","status":"active","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{
+				user: tt.fields.user,
+			}
+			u.Update(tt.args.w, tt.args.r)
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestUsers_Patch(t *testing.T) {
+	newRequest := func(contentType, body string) *http.Request {
+		r := httptest.NewRequest("PATCH", "/uuid", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return withIfMatch(r, `"1"`)
+	}
+	getTod := func(ctx context.Context, id string) (models.User, error) {
+		return models.User{ID: "1", Name: "tod", Email: "tod@example.com", Status: models.UserStatusActive, Version: 1}, nil
+	}
+
+	type fields struct {
+		user UsersService
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantCode int
+		wantBody []byte
+	}{
+		{
+			name: "failure when content type is not a patch format",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/json", `{"name": "mike"}`),
+			},
+			wantCode: http.StatusUnsupportedMediaType,
+			wantBody: []byte(`{"type":"about:blank","title":"Unsupported Media Type","status":415,"detail":"unsupported media type","instance":"/uuid","code":"unsupported_media_type","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when user can't be fetched",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{}, fmt.Errorf("failed to get user: %w", models.NotFoundErr)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/merge-patch+json", `{"name": "mike"}`),
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: []byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/uuid","code":"not_found","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when If-Match is older than the current version",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{ID: "1", Name: "tod", Version: 2}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/merge-patch+json", `{"name": "mike"}`),
+			},
+			wantCode: http.StatusPreconditionFailed,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Failed","status":412,"detail":"version mismatch","instance":"/uuid","code":"version_mismatch","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when json patch can't be decoded",
+			fields: fields{
+				user: &UsersServiceMock{GetFunc: getTod},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/json-patch+json", `{"op": "replace"}`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"failed to decode json patch: json: cannot unmarshal object into Go value of type jsonpatch.Patch","instance":"/uuid","code":"malformed_body","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when json patch test operation fails",
+			fields: fields{
+				user: &UsersServiceMock{GetFunc: getTod},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/json-patch+json", `[{"op": "test", "path": "/name", "value": "mike"}]`),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: []byte(`{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"failed to apply json patch: testing value /name failed: test failed","instance":"/uuid","code":"unprocessable","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when patch changes the id",
+			fields: fields{
+				user: &UsersServiceMock{GetFunc: getTod},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/merge-patch+json", `{"id": "2"}`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/uuid","code":"invalid_argument","retryable":false,"errors":[{"field":"id","code":"immutable","message":"id can not be changed"}]}` + "\n"),
+		},
+		{
+			name: "failure when patched name is invalid",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: getTod,
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/merge-patch+json", `{"name": null}`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/uuid","code":"invalid_argument","retryable":false,"errors":[{"field":"name","code":"required","message":"value is required"}]}` + "\n"),
+		},
+		{
+			name: "success with merge patch",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: getTod,
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{ID: "1", Name: "mike", Email: "tod@example.com", Version: 1}, usr)
+						usr.Version++
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/merge-patch+json", `{"name": "mike"}`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"mike","email":"tod@example.com","status":"","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "success with json patch",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: getTod,
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{ID: "1", Name: "mike", Email: "tod@example.com", Version: 1}, usr)
+						usr.Version++
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/json-patch+json", `[{"op": "test", "path": "/name", "value": "tod"}, {"op": "replace", "path": "/name", "value": "mike"}]`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"mike","email":"tod@example.com","status":"","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "success with merge patch of a user without email",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{ID: "1", Name: "tod", Status: models.UserStatusActive, Version: 1}, nil
+					},
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{ID: "1", Name: "mike", Version: 1}, usr)
+						usr.Version++
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("application/merge-patch+json", `{"name": "mike"}`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"mike","status":"","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "success when If-Match is *",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: getTod,
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, int64(1), usr.Version, "the patch is stored at the version it was computed from")
+						usr.Version++
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(newRequest("application/merge-patch+json", `{"name": "mike"}`), `*`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"mike","email":"tod@example.com","status":"","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "success when If-Match lists the current version",
+			fields: fields{
+				user: &UsersServiceMock{
+					GetFunc: getTod,
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, int64(1), usr.Version, "the patch is stored at the version it was computed from")
+						usr.Version++
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(newRequest("application/merge-patch+json", `{"name": "mike"}`), `"3", "1"`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"mike","email":"tod@example.com","status":"","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{
+				user: tt.fields.user,
+			}
+			u.Patch(tt.args.w, tt.args.r)
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestUsers_Delete(t *testing.T) {
+	type fields struct {
+		user UsersService
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantCode int
+		wantBody []byte
+	}{
+		{
+			name: "failure when If-Match is missing",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("DELETE", "/uuid", nil),
+			},
+			wantCode: http.StatusPreconditionRequired,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Required","status":428,"detail":"precondition required","instance":"/uuid","code":"precondition_required","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when version is stale",
+			fields: fields{
+				user: &UsersServiceMock{
+					DeleteFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) error {
+						assert.Equal(t, int64(1), version)
+						return models.VersionMismatchErr
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("DELETE", "/uuid", nil), `"1"`),
+			},
+			wantCode: http.StatusPreconditionFailed,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Failed","status":412,"detail":"version mismatch","instance":"/uuid","code":"version_mismatch","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when service fails to delete",
+			fields: fields{
+				user: &UsersServiceMock{
+					DeleteFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) error {
+						return fmt.Errorf("failed to delete user: %w", models.NotFoundErr)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("DELETE", "/uuid", nil), `"1"`),
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: []byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/uuid","code":"not_found","retryable":false}` + "\n"),
+		},
+		{
+			name: "success",
+			fields: fields{
+				user: &UsersServiceMock{
+					DeleteFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) error {
+						assert.Equal(t, models.StatusChange{Actor: "admin"}, change)
+						return nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: func() *http.Request {
+					r := withIfMatch(httptest.NewRequest("DELETE", "/uuid", nil), `"1"`)
+					r.Header.Set(ActorHeader, "admin")
+					return r
+				}(),
+			},
+			wantCode: http.StatusNoContent,
+			wantBody: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{
+				user: tt.fields.user,
+			}
+			u.Delete(tt.args.w, tt.args.r)
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestUsers_Restore(t *testing.T) {
+	type fields struct {
+		user UsersService
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantCode int
+		wantBody []byte
+	}{
+		{
+			name: "failure when service fails to restore",
+			fields: fields{
+				user: &UsersServiceMock{
+					RestoreFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+						return models.User{}, fmt.Errorf("failed to get user: %w", models.NotFoundErr)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:restore", nil), `"1"`),
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: []byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/uuid:restore","code":"not_found","retryable":false}` + "\n"),
+		},
+		{
+			name: "success",
+			fields: fields{
+				user: &UsersServiceMock{
+					RestoreFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+						return models.User{ID: "1", Name: "tod"}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:restore", nil), `"1"`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"tod","status":"","version":0,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{
+				user: tt.fields.user,
+			}
+			u.Restore(tt.args.w, tt.args.r)
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestUsers_Suspend(t *testing.T) {
+	type fields struct {
+		user UsersService
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantCode int
+		wantBody []byte
+	}{
+		{
+			name: "failure when If-Match is missing",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("POST", "/uuid:suspend", nil),
+			},
+			wantCode: http.StatusPreconditionRequired,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Required","status":428,"detail":"precondition required","instance":"/uuid:suspend","code":"precondition_required","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when payload can't be decoded",
+			fields: fields{
+				user: &UsersServiceMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:suspend", strings.NewReader(`{"why": "spam"}`)), `"1"`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/uuid:suspend","code":"invalid_argument","retryable":false,"errors":[{"field":"why","code":"unknown","message":"field is not allowed"}]}` + "\n"),
+		},
+		{
+			name: "failure when transition is illegal",
+			fields: fields{
+				user: &UsersServiceMock{
+					SuspendFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+						return models.User{}, fmt.Errorf("failed to change user status: %w", &models.Error{
+							Code:    "illegal_transition",
+							Message: "a pending user can't become suspended",
+							Err:     models.IllegalTransitionErr,
+						})
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:suspend", nil), `"1"`),
+			},
+			wantCode: http.StatusConflict,
+			wantBody: []byte(`{"type":"about:blank","title":"Conflict","status":409,"detail":"a pending user can't become suspended","instance":"/uuid:suspend","code":"illegal_transition","retryable":false}` + "\n"),
+		},
+		{
+			name: "success",
+			fields: fields{
+				user: &UsersServiceMock{
+					SuspendFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+						assert.Equal(t, int64(1), version)
+						assert.Equal(t, models.StatusChange{Actor: "admin", Reason: "spam"}, change)
+						return models.User{ID: "1", Name: "tod", Status: models.UserStatusSuspended, Version: 2}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: func() *http.Request {
+					r := withIfMatch(httptest.NewRequest("POST", "/uuid:suspend", strings.NewReader(`{"reason": " spam "}`)), `"1"`)
+					r.Header.Set(ActorHeader, "admin")
+					return r
+				}(),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"tod","status":"suspended","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "success without payload",
+			fields: fields{
+				user: &UsersServiceMock{
+					SuspendFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+						assert.Equal(t, models.StatusChange{Actor: "anonymous"}, change)
+						return models.User{ID: "1", Name: "tod", Status: models.UserStatusSuspended, Version: 2}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:suspend", nil), `"1"`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"tod","status":"suspended","version":2,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "success when If-Match is *",
+			fields: fields{
+				user: &UsersServiceMock{
+					SuspendFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+						assert.Equal(t, models.AnyVersion, version)
+						return models.User{ID: "1", Name: "tod", Status: models.UserStatusSuspended, Version: 5}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:suspend", nil), `*`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"tod","status":"suspended","version":5,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "success when If-Match lists the current version",
+			fields: fields{
+				user: func() *UsersServiceMock {
+					var tried []int64
+					return &UsersServiceMock{
+						SuspendFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+							tried = append(tried, version)
+							if version != 3 {
+								return models.User{}, fmt.Errorf("failed to change user status: %w", models.VersionMismatchErr)
+							}
+							assert.Equal(t, []int64{2, 3}, tried, "weak tags never match")
+							return models.User{ID: "1", Name: "tod", Status: models.UserStatusSuspended, Version: 4}, nil
+						},
+					}
+				}(),
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:suspend", nil), `W/"1", "2" ,"3", "4"`),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"id":"1","name":"tod","status":"suspended","version":4,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"),
+		},
+		{
+			name: "failure when If-Match lists no current version",
+			fields: fields{
+				user: &UsersServiceMock{
+					SuspendFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+						return models.User{}, fmt.Errorf("failed to change user status: %w", models.VersionMismatchErr)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withIfMatch(httptest.NewRequest("POST", "/uuid:suspend", nil), `"1", "2"`),
+			},
+			wantCode: http.StatusPreconditionFailed,
+			wantBody: []byte(`{"type":"about:blank","title":"Precondition Failed","status":412,"detail":"version mismatch","instance":"/uuid:suspend","code":"version_mismatch","retryable":false}` + "\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{
+				user: tt.fields.user,
+			}
+			u.Suspend(tt.args.w, tt.args.r)
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestUsers_Transitions(t *testing.T) {
+	type fields struct {
+		user UsersService
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		wantCode int
+		wantBody []byte
+	}{
+		{
+			name: "failure when service fails",
+			fields: fields{
+				user: &UsersServiceMock{
+					TransitionsFunc: func(ctx context.Context, id string) ([]models.UserTransition, error) {
+						return nil, errors.New("database is locked")
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/uuid/transitions", nil),
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/uuid/transitions","code":"internal_server_error","retryable":false}` + "\n"),
+		},
+		{
+			name: "failure when user is not found",
+			fields: fields{
+				user: &UsersServiceMock{
+					TransitionsFunc: func(ctx context.Context, id string) ([]models.UserTransition, error) {
+						return nil, fmt.Errorf("failed to list user transitions: %w", models.NotFoundErr)
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/uuid/transitions", nil),
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: []byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/uuid/transitions","code":"not_found","retryable":false}` + "\n"),
+		},
+		{
+			name: "success without transitions",
+			fields: fields{
+				user: &UsersServiceMock{
+					TransitionsFunc: func(ctx context.Context, id string) ([]models.UserTransition, error) {
+						return nil, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/uuid/transitions", nil),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"transitions":[]}` + "\n"),
+		},
+		{
+			name: "success",
+			fields: fields{
+				user: &UsersServiceMock{
+					TransitionsFunc: func(ctx context.Context, id string) ([]models.UserTransition, error) {
+						return []models.UserTransition{{
+							UserID:    "1",
+							From:      models.UserStatusActive,
+							To:        models.UserStatusSuspended,
+							Actor:     "admin",
+							Reason:    "spam",
+							CreatedAt: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+						}}, nil
+					},
+				},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("GET", "/uuid/transitions", nil),
+			},
+			wantCode: http.StatusOK,
+			wantBody: []byte(`{"transitions":[{"user_id":"1","from":"active","to":"suspended","actor":"admin","reason":"spam","created_at":"2026-10-18T09:00:00Z"}]}` + "\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{
+				user: tt.fields.user,
+			}
+			u.Transitions(tt.args.w, tt.args.r)
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			assert.Equal(t, tt.args.w.Body.Bytes(), tt.wantBody)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, "application/problem+json", tt.args.w.Header().Get("Content-Type"))
+			}
 		})
 	}
 }
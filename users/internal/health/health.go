@@ -0,0 +1,154 @@
+// Package health serves the liveness, readiness and startup probes of the
+// service from a registry of dependency checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency works.
+type Check func(ctx context.Context) error
+
+// Probe is the question a check helps answer.
+type Probe int
+
+const (
+	// Liveness tells whether the process should be restarted.
+	Liveness Probe = iota
+	// Readiness tells whether the instance should receive traffic.
+	Readiness
+	// Startup tells whether the instance has finished starting.
+	Startup
+)
+
+const (
+	statusOK       = "ok"
+	statusFailing  = "failing"
+	statusStarting = "starting"
+	statusStopping = "stopping"
+)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Registry holds the checks of every probe and whether the application has
+// started or is shutting down.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[Probe][]namedCheck
+
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry returns a Registry that gives every check timeout to finish.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout, checks: map[Probe][]namedCheck{}}
+}
+
+// Register adds check under name to every one of probes.
+func (r *Registry) Register(name string, check Check, probes ...Probe) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range probes {
+		r.checks[p] = append(r.checks[p], namedCheck{name: name, check: check})
+	}
+}
+
+// Started marks the application as started. Until then the startup and
+// readiness probes fail.
+func (r *Registry) Started() {
+	r.started.Store(true)
+}
+
+// ShuttingDown makes the readiness probe fail, so that load balancers stop
+// sending traffic before the server shuts down.
+func (r *Registry) ShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Report is the response body of a probe.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Run runs the checks of probe concurrently. The report is only ok when
+// every check passed.
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	switch {
+	case probe == Readiness && r.shuttingDown.Load():
+		return Report{Status: statusStopping}
+	case probe != Liveness && !r.started.Load():
+		return Report{Status: statusStarting}
+	}
+
+	r.mu.RLock()
+	checks := r.checks[probe]
+	r.mu.RUnlock()
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: statusOK}
+	if len(checks) > 0 {
+		report.Checks = make(map[string]string, len(checks))
+	}
+	for i, c := range checks {
+		report.Checks[c.name] = statusOK
+		if results[i] != nil {
+			report.Status = statusFailing
+			report.Checks[c.name] = results[i].Error()
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c namedCheck) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("check panicked: %v", p)
+		}
+	}()
+	return c.check(ctx)
+}
+
+// Handler serves the report of probe with 200 when it is ok and 503
+// otherwise.
+func (r *Registry) Handler(probe Probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context(), probe)
+
+		status := http.StatusOK
+		if report.Status != statusOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Handler(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("database is locked") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	panicking := func(ctx context.Context) error { panic("boom") }
+
+	type fields struct {
+		checks       map[string]Check
+		started      bool
+		shuttingDown bool
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		probe    Probe
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "success liveness without checks",
+			probe:    Liveness,
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"ok"}` + "\n",
+		},
+		{
+			name:     "success readiness",
+			fields:   fields{checks: map[string]Check{"database": ok}, started: true},
+			probe:    Readiness,
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"ok","checks":{"database":"ok"}}` + "\n",
+		},
+		{
+			name:     "success startup",
+			fields:   fields{checks: map[string]Check{"database": ok}, started: true},
+			probe:    Startup,
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"ok","checks":{"database":"ok"}}` + "\n",
+		},
+		{
+			name:     "failure readiness before started",
+			fields:   fields{checks: map[string]Check{"database": ok}},
+			probe:    Readiness,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"starting"}` + "\n",
+		},
+		{
+			name:     "failure startup before started",
+			fields:   fields{checks: map[string]Check{"database": ok}},
+			probe:    Startup,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"starting"}` + "\n",
+		},
+		{
+			name:     "failure readiness when shutting down",
+			fields:   fields{checks: map[string]Check{"database": ok}, started: true, shuttingDown: true},
+			probe:    Readiness,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"stopping"}` + "\n",
+		},
+		{
+			name:     "success liveness when shutting down",
+			fields:   fields{started: true, shuttingDown: true},
+			probe:    Liveness,
+			wantCode: http.StatusOK,
+			wantBody: `{"status":"ok"}` + "\n",
+		},
+		{
+			name:     "failure when a check fails",
+			fields:   fields{checks: map[string]Check{"database": failing, "cache": ok}, started: true},
+			probe:    Readiness,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"failing","checks":{"cache":"ok","database":"database is locked"}}` + "\n",
+		},
+		{
+			name:     "failure when a check times out",
+			fields:   fields{checks: map[string]Check{"database": slow}, started: true},
+			probe:    Readiness,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"failing","checks":{"database":"context deadline exceeded"}}` + "\n",
+		},
+		{
+			name:     "failure when a check panics",
+			fields:   fields{checks: map[string]Check{"database": panicking}, started: true},
+			probe:    Readiness,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"status":"failing","checks":{"database":"check panicked: boom"}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(10 * time.Millisecond)
+			for name, check := range tt.fields.checks {
+				r.Register(name, check, Readiness, Startup)
+			}
+			if tt.fields.started {
+				r.Started()
+			}
+			if tt.fields.shuttingDown {
+				r.ShuttingDown()
+			}
+
+			w := httptest.NewRecorder()
+			r.Handler(tt.probe)(w, httptest.NewRequest("GET", "/", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
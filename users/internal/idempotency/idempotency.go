@@ -0,0 +1,186 @@
+// Package idempotency lets clients safely retry non-idempotent requests by
+// sending an Idempotency-Key header. The first request with a key is executed
+// and its response stored; repeats with the same key and payload get the
+// stored response back without executing the handler again.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/problem"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// Record is what is kept for a key. Completed is false while the first
+// request is still being executed. Besides the body the response headers a
+// client needs to go on are kept: the ETag of a created user is what its
+// next write has to send in If-Match.
+type Record struct {
+	RequestHash string
+	Completed   bool
+	Status      int
+	ContentType string
+	ETag        string
+	Location    string
+	Body        []byte
+}
+
+//go:generate moq -rm -out store_mock.go . Store
+type Store interface {
+	// Begin reserves key for a request with requestHash until lockedUntil.
+	// When the key is already reserved or completed and not expired the
+	// existing record is returned and started is false.
+	Begin(ctx context.Context, key, requestHash string, lockedUntil time.Time) (rec Record, started bool, err error)
+	// Complete stores the response for key and keeps it until expiresAt.
+	Complete(ctx context.Context, key string, rec Record, expiresAt time.Time) error
+	// Release drops the reservation of key so the request can be retried.
+	Release(ctx context.Context, key string) error
+	// DeleteExpired removes records that expired before now.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Middleware makes the wrapped handler idempotent for requests carrying an
+// Idempotency-Key header. Responses are kept for ttl. A request holds its key
+// for at most lockTimeout, after which a retry may execute it again.
+//
+// Server errors (5xx) are not stored, so a retry after a failure executes the
+// request again.
+func Middleware(store Store, ttl, lockTimeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				problem.Write(w, r, models.InvalidFieldErr(models.InvalidErr, HeaderKey, "too_long", fmt.Sprintf("key must not be longer than %d characters", maxKeyLength)))
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				problem.Write(w, r, problem.DecodeErr(err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := r.Context()
+			hash := requestHash(r, body)
+
+			rec, started, err := store.Begin(ctx, key, hash, time.Now().Add(lockTimeout))
+			if err != nil {
+				problem.Write(w, r, fmt.Errorf("failed to reserve idempotency key: %w", err))
+				return
+			}
+
+			if !started {
+				replay(w, r, rec, hash)
+				return
+			}
+
+			rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+			executed := false
+			defer func() {
+				if executed {
+					return
+				}
+				// The handler failed or panicked, let the client retry. The
+				// request context may already be gone at this point.
+				_ = store.Release(context.Background(), key)
+			}()
+
+			next.ServeHTTP(rw, r)
+
+			if rw.status >= http.StatusInternalServerError {
+				return
+			}
+			executed = true
+
+			rec = Record{
+				RequestHash: hash,
+				Completed:   true,
+				Status:      rw.status,
+				ContentType: rw.Header().Get("Content-Type"),
+				ETag:        rw.Header().Get("ETag"),
+				Location:    rw.Header().Get("Location"),
+				Body:        rw.body.Bytes(),
+			}
+			// When the response can't be stored the key stays reserved until
+			// lockTimeout, which is safer than letting a retry execute the
+			// request a second time right away.
+			_ = store.Complete(context.Background(), key, rec, time.Now().Add(ttl))
+		})
+	}
+}
+
+// replay answers a repeated request from the stored record.
+func replay(w http.ResponseWriter, r *http.Request, rec Record, hash string) {
+	switch {
+	case rec.RequestHash != hash:
+		problem.Write(w, r, &models.Error{
+			Code:    "idempotency_key_reused",
+			Message: "key was already used for a different request",
+			Err:     problem.UnprocessableErr,
+		})
+	case !rec.Completed:
+		problem.Write(w, r, &models.Error{
+			Code:      "idempotency_key_in_use",
+			Message:   "a request with this key is still being processed",
+			Retryable: true,
+			Err:       problem.ConflictErr,
+		})
+	default:
+		for name, value := range map[string]string{"Content-Type": rec.ContentType, "ETag": rec.ETag, "Location": rec.Location} {
+			if value != "" {
+				w.Header().Set(name, value)
+			}
+		}
+		w.Header().Set(HeaderReplayed, "true")
+		w.WriteHeader(rec.Status)
+		_, _ = w.Write(rec.Body)
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes the response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,246 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	newRequest := func(key, body string) *http.Request {
+		r := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(HeaderKey, key)
+		}
+		return r
+	}
+	hashOf := func(body string) string {
+		return requestHash(httptest.NewRequest("POST", "/users", nil), []byte(body))
+	}
+	created := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"1"`)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(b)
+	})
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "", http.StatusInternalServerError)
+	})
+
+	type fields struct {
+		store   *StoreMock
+		handler http.Handler
+	}
+	type args struct {
+		w *httptest.ResponseRecorder
+		r *http.Request
+	}
+	tests := []struct {
+		name         string
+		fields       fields
+		args         args
+		wantCode     int
+		wantBody     string
+		wantReplayed bool
+		wantComplete int
+		wantRelease  int
+	}{
+		{
+			name: "no key passes through",
+			fields: fields{
+				store:   &StoreMock{},
+				handler: created,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("", `{"name":"mike"}`),
+			},
+			wantCode: http.StatusCreated,
+			wantBody: `{"name":"mike"}`,
+		},
+		{
+			name: "first request executes and is stored",
+			fields: fields{
+				store: &StoreMock{
+					BeginFunc: func(ctx context.Context, key, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+						assert.Equal(t, "k1", key)
+						return Record{RequestHash: requestHash}, true, nil
+					},
+					CompleteFunc: func(ctx context.Context, key string, rec Record, expiresAt time.Time) error {
+						assert.Equal(t, Record{
+							RequestHash: hashOf(`{"name":"mike"}`),
+							Completed:   true,
+							Status:      http.StatusCreated,
+							ContentType: "application/json",
+							ETag:        `"1"`,
+							Body:        []byte(`{"name":"mike"}`),
+						}, rec)
+						assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+						return nil
+					},
+				},
+				handler: created,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("k1", `{"name":"mike"}`),
+			},
+			wantCode:     http.StatusCreated,
+			wantBody:     `{"name":"mike"}`,
+			wantComplete: 1,
+		},
+		{
+			name: "repeat is replayed",
+			fields: fields{
+				store: &StoreMock{
+					BeginFunc: func(ctx context.Context, key, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+						return Record{
+							RequestHash: requestHash,
+							Completed:   true,
+							Status:      http.StatusCreated,
+							ContentType: "application/json",
+							Body:        []byte(`{"id":"1"}`),
+						}, false, nil
+					},
+				},
+				handler: failing,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("k1", `{"name":"mike"}`),
+			},
+			wantCode:     http.StatusCreated,
+			wantBody:     `{"id":"1"}`,
+			wantReplayed: true,
+		},
+		{
+			name: "same key with another payload is rejected",
+			fields: fields{
+				store: &StoreMock{
+					BeginFunc: func(ctx context.Context, key, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+						return Record{RequestHash: hashOf(`{"name":"tod"}`), Completed: true, Status: http.StatusCreated}, false, nil
+					},
+				},
+				handler: failing,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("k1", `{"name":"mike"}`),
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"key was already used for a different request","instance":"/users","code":"idempotency_key_reused","retryable":false}` + "\n",
+		},
+		{
+			name: "concurrent request with the same key is rejected",
+			fields: fields{
+				store: &StoreMock{
+					BeginFunc: func(ctx context.Context, key, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+						return Record{RequestHash: requestHash}, false, nil
+					},
+				},
+				handler: failing,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("k1", `{"name":"mike"}`),
+			},
+			wantCode: http.StatusConflict,
+			wantBody: `{"type":"about:blank","title":"Conflict","status":409,"detail":"a request with this key is still being processed","instance":"/users","code":"idempotency_key_in_use","retryable":true}` + "\n",
+		},
+		{
+			name: "server error releases the key",
+			fields: fields{
+				store: &StoreMock{
+					BeginFunc: func(ctx context.Context, key, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+						return Record{RequestHash: requestHash}, true, nil
+					},
+					ReleaseFunc: func(ctx context.Context, key string) error {
+						assert.Equal(t, "k1", key)
+						return nil
+					},
+				},
+				handler: failing,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest("k1", `{"name":"mike"}`),
+			},
+			wantCode:    http.StatusInternalServerError,
+			wantBody:    "\n",
+			wantRelease: 1,
+		},
+		{
+			name: "key too long",
+			fields: fields{
+				store:   &StoreMock{},
+				handler: created,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: newRequest(strings.Repeat("k", maxKeyLength+1), `{"name":"mike"}`),
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"request contains invalid fields","instance":"/users","code":"invalid_argument","retryable":false,"errors":[{"field":"Idempotency-Key","code":"too_long","message":"key must not be longer than 255 characters"}]}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Middleware(tt.fields.store, time.Hour, time.Minute)(tt.fields.handler)
+			h.ServeHTTP(tt.args.w, tt.args.r)
+
+			assert.Equal(t, tt.wantCode, tt.args.w.Code)
+			assert.Equal(t, tt.wantBody, tt.args.w.Body.String())
+			assert.Equal(t, tt.wantReplayed, tt.args.w.Header().Get(HeaderReplayed) == "true")
+			assert.Len(t, tt.fields.store.CompleteCalls(), tt.wantComplete)
+			assert.Len(t, tt.fields.store.ReleaseCalls(), tt.wantRelease)
+		})
+	}
+}
+
+func TestMiddleware_replayHeaders(t *testing.T) {
+	var stored *Record
+	store := &StoreMock{
+		BeginFunc: func(ctx context.Context, key, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+			if stored != nil {
+				return *stored, false, nil
+			}
+			return Record{RequestHash: requestHash}, true, nil
+		},
+		CompleteFunc: func(ctx context.Context, key string, rec Record, expiresAt time.Time) error {
+			stored = &rec
+			return nil
+		},
+	}
+	h := Middleware(store, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("Location", "/users/1")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"mike"}`))
+		r.Header.Set(HeaderKey, "k1")
+		h.ServeHTTP(w, r)
+		return w
+	}
+	first, replayed := serve(), serve()
+
+	assert.Equal(t, "true", replayed.Header().Get(HeaderReplayed))
+	assert.Equal(t, first.Code, replayed.Code)
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+	for _, name := range []string{"Content-Type", "ETag", "Location"} {
+		assert.Equal(t, first.Header().Get(name), replayed.Header().Get(name), name)
+	}
+	assert.Empty(t, replayed.Header().Get("X-Request-Id"), "only the headers the client needs are kept")
+}
@@ -0,0 +1,256 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Ensure, that StoreMock does implement Store.
+// If this is not the case, regenerate this file with moq.
+var _ Store = &StoreMock{}
+
+// StoreMock is a mock implementation of Store.
+//
+//	func TestSomethingThatUsesStore(t *testing.T) {
+//
+//		// make and configure a mocked Store
+//		mockedStore := &StoreMock{
+//			BeginFunc: func(ctx context.Context, key string, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+//				panic("mock out the Begin method")
+//			},
+//			CompleteFunc: func(ctx context.Context, key string, rec Record, expiresAt time.Time) error {
+//				panic("mock out the Complete method")
+//			},
+//			DeleteExpiredFunc: func(ctx context.Context, now time.Time) (int64, error) {
+//				panic("mock out the DeleteExpired method")
+//			},
+//			ReleaseFunc: func(ctx context.Context, key string) error {
+//				panic("mock out the Release method")
+//			},
+//		}
+//
+//		// use mockedStore in code that requires Store
+//		// and then make assertions.
+//
+//	}
+type StoreMock struct {
+	// BeginFunc mocks the Begin method.
+	BeginFunc func(ctx context.Context, key string, requestHash string, lockedUntil time.Time) (Record, bool, error)
+
+	// CompleteFunc mocks the Complete method.
+	CompleteFunc func(ctx context.Context, key string, rec Record, expiresAt time.Time) error
+
+	// DeleteExpiredFunc mocks the DeleteExpired method.
+	DeleteExpiredFunc func(ctx context.Context, now time.Time) (int64, error)
+
+	// ReleaseFunc mocks the Release method.
+	ReleaseFunc func(ctx context.Context, key string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Begin holds details about calls to the Begin method.
+		Begin []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// RequestHash is the requestHash argument value.
+			RequestHash string
+			// LockedUntil is the lockedUntil argument value.
+			LockedUntil time.Time
+		}
+		// Complete holds details about calls to the Complete method.
+		Complete []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Rec is the rec argument value.
+			Rec Record
+			// ExpiresAt is the expiresAt argument value.
+			ExpiresAt time.Time
+		}
+		// DeleteExpired holds details about calls to the DeleteExpired method.
+		DeleteExpired []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Now is the now argument value.
+			Now time.Time
+		}
+		// Release holds details about calls to the Release method.
+		Release []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
+	}
+	lockBegin         sync.RWMutex
+	lockComplete      sync.RWMutex
+	lockDeleteExpired sync.RWMutex
+	lockRelease       sync.RWMutex
+}
+
+// Begin calls BeginFunc.
+func (mock *StoreMock) Begin(ctx context.Context, key string, requestHash string, lockedUntil time.Time) (Record, bool, error) {
+	if mock.BeginFunc == nil {
+		panic("StoreMock.BeginFunc: method is nil but Store.Begin was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Key         string
+		RequestHash string
+		LockedUntil time.Time
+	}{
+		Ctx:         ctx,
+		Key:         key,
+		RequestHash: requestHash,
+		LockedUntil: lockedUntil,
+	}
+	mock.lockBegin.Lock()
+	mock.calls.Begin = append(mock.calls.Begin, callInfo)
+	mock.lockBegin.Unlock()
+	return mock.BeginFunc(ctx, key, requestHash, lockedUntil)
+}
+
+// BeginCalls gets all the calls that were made to Begin.
+// Check the length with:
+//
+//	len(mockedStore.BeginCalls())
+func (mock *StoreMock) BeginCalls() []struct {
+	Ctx         context.Context
+	Key         string
+	RequestHash string
+	LockedUntil time.Time
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Key         string
+		RequestHash string
+		LockedUntil time.Time
+	}
+	mock.lockBegin.RLock()
+	calls = mock.calls.Begin
+	mock.lockBegin.RUnlock()
+	return calls
+}
+
+// Complete calls CompleteFunc.
+func (mock *StoreMock) Complete(ctx context.Context, key string, rec Record, expiresAt time.Time) error {
+	if mock.CompleteFunc == nil {
+		panic("StoreMock.CompleteFunc: method is nil but Store.Complete was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Key       string
+		Rec       Record
+		ExpiresAt time.Time
+	}{
+		Ctx:       ctx,
+		Key:       key,
+		Rec:       rec,
+		ExpiresAt: expiresAt,
+	}
+	mock.lockComplete.Lock()
+	mock.calls.Complete = append(mock.calls.Complete, callInfo)
+	mock.lockComplete.Unlock()
+	return mock.CompleteFunc(ctx, key, rec, expiresAt)
+}
+
+// CompleteCalls gets all the calls that were made to Complete.
+// Check the length with:
+//
+//	len(mockedStore.CompleteCalls())
+func (mock *StoreMock) CompleteCalls() []struct {
+	Ctx       context.Context
+	Key       string
+	Rec       Record
+	ExpiresAt time.Time
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Key       string
+		Rec       Record
+		ExpiresAt time.Time
+	}
+	mock.lockComplete.RLock()
+	calls = mock.calls.Complete
+	mock.lockComplete.RUnlock()
+	return calls
+}
+
+// DeleteExpired calls DeleteExpiredFunc.
+func (mock *StoreMock) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	if mock.DeleteExpiredFunc == nil {
+		panic("StoreMock.DeleteExpiredFunc: method is nil but Store.DeleteExpired was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Now time.Time
+	}{
+		Ctx: ctx,
+		Now: now,
+	}
+	mock.lockDeleteExpired.Lock()
+	mock.calls.DeleteExpired = append(mock.calls.DeleteExpired, callInfo)
+	mock.lockDeleteExpired.Unlock()
+	return mock.DeleteExpiredFunc(ctx, now)
+}
+
+// DeleteExpiredCalls gets all the calls that were made to DeleteExpired.
+// Check the length with:
+//
+//	len(mockedStore.DeleteExpiredCalls())
+func (mock *StoreMock) DeleteExpiredCalls() []struct {
+	Ctx context.Context
+	Now time.Time
+} {
+	var calls []struct {
+		Ctx context.Context
+		Now time.Time
+	}
+	mock.lockDeleteExpired.RLock()
+	calls = mock.calls.DeleteExpired
+	mock.lockDeleteExpired.RUnlock()
+	return calls
+}
+
+// Release calls ReleaseFunc.
+func (mock *StoreMock) Release(ctx context.Context, key string) error {
+	if mock.ReleaseFunc == nil {
+		panic("StoreMock.ReleaseFunc: method is nil but Store.Release was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockRelease.Lock()
+	mock.calls.Release = append(mock.calls.Release, callInfo)
+	mock.lockRelease.Unlock()
+	return mock.ReleaseFunc(ctx, key)
+}
+
+// ReleaseCalls gets all the calls that were made to Release.
+// Check the length with:
+//
+//	len(mockedStore.ReleaseCalls())
+func (mock *StoreMock) ReleaseCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockRelease.RLock()
+	calls = mock.calls.Release
+	mock.lockRelease.RUnlock()
+	return calls
+}
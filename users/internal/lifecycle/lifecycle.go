@@ -0,0 +1,131 @@
+// Package lifecycle starts the components of an application in dependency
+// order and stops them in reverse order.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Component is a part of the application such as a database pool, an HTTP
+// server or a background worker. Every step is optional.
+type Component struct {
+	Name string
+	// Start brings the component up and returns once it is usable.
+	Start func(ctx context.Context) error
+	// Ping checks that the component works after Start.
+	Ping func(ctx context.Context) error
+	// Run does the work of long running components, like serving requests.
+	// Its context is cancelled when the component is stopped. An error
+	// returned before that shuts the application down.
+	Run func(ctx context.Context) error
+	// Stop releases what Start acquired.
+	Stop func(ctx context.Context) error
+}
+
+// Manager owns the components of an application.
+type Manager struct {
+	components  []Component
+	stopTimeout time.Duration
+}
+
+// New returns a Manager that gives its components stopTimeout to stop.
+func New(stopTimeout time.Duration) *Manager {
+	return &Manager{stopTimeout: stopTimeout}
+}
+
+// Add appends components. A component may depend on the ones added before
+// it.
+func (m *Manager) Add(cs ...Component) {
+	m.components = append(m.components, cs...)
+}
+
+type running struct {
+	Component
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Run starts and pings the components in order, then waits until ctx is done
+// or a component fails, and finally stops the started components in reverse
+// order. It returns what made the application stop, if that was not ctx, and
+// every error met while stopping.
+func (m *Manager) Run(ctx context.Context) error {
+	failed := make(chan error, len(m.components))
+	started := make([]running, 0, len(m.components))
+
+	err := func() error {
+		for _, c := range m.components {
+			slog.Info("starting component", "component", c.Name)
+			if c.Start != nil {
+				if err := c.Start(ctx); err != nil {
+					return fmt.Errorf("failed to start %s: %w", c.Name, err)
+				}
+			}
+
+			r := running{Component: c, cancel: func() {}, done: make(chan struct{})}
+			started = append(started, r)
+
+			if c.Ping != nil {
+				if err := c.Ping(ctx); err != nil {
+					return fmt.Errorf("failed to ping %s: %w", c.Name, err)
+				}
+			}
+
+			if c.Run == nil {
+				close(r.done)
+				continue
+			}
+			runCtx, cancel := context.WithCancel(context.Background())
+			started[len(started)-1].cancel = cancel
+			go func() {
+				defer close(r.done)
+				if err := r.Run(runCtx); err != nil && runCtx.Err() == nil {
+					failed <- fmt.Errorf("%s failed: %w", r.Name, err)
+				}
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-failed:
+			return err
+		}
+	}()
+	if err != nil {
+		slog.Error("application failed", "error", err)
+	}
+
+	return errors.Join(err, m.stop(started))
+}
+
+// stop stops the components in reverse order, waiting for each one to finish
+// running before moving on to the next.
+func (m *Manager) stop(started []running) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.stopTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		slog.Info("stopping component", "component", c.Name)
+
+		c.cancel()
+		if c.Stop != nil {
+			if err := c.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.Name, err))
+			}
+		}
+
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.Name, ctx.Err()))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errBoom = errors.New("boom")
+
+// recorder builds components that append their steps to calls.
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) component(name string) Component {
+	return Component{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			r.calls = append(r.calls, "start "+name)
+			return nil
+		},
+		Ping: func(ctx context.Context) error {
+			r.calls = append(r.calls, "ping "+name)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			r.calls = append(r.calls, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestManager_Run(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(r *recorder) []Component
+		interrupt bool
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name: "success starts in order and stops in reverse order",
+			configure: func(r *recorder) []Component {
+				return []Component{r.component("db"), r.component("http")}
+			},
+			interrupt: true,
+			wantCalls: []string{"start db", "ping db", "start http", "ping http", "stop http", "stop db"},
+		},
+		{
+			name: "failure to start stops the started components",
+			configure: func(r *recorder) []Component {
+				http := r.component("http")
+				http.Start = func(ctx context.Context) error {
+					r.calls = append(r.calls, "start http")
+					return errBoom
+				}
+				return []Component{r.component("db"), http, r.component("workers")}
+			},
+			wantCalls: []string{"start db", "ping db", "start http", "stop db"},
+			wantErr:   errBoom,
+		},
+		{
+			name: "failure to ping stops the component",
+			configure: func(r *recorder) []Component {
+				db := r.component("db")
+				db.Ping = func(ctx context.Context) error {
+					r.calls = append(r.calls, "ping db")
+					return errBoom
+				}
+				return []Component{db, r.component("http")}
+			},
+			wantCalls: []string{"start db", "ping db", "stop db"},
+			wantErr:   errBoom,
+		},
+		{
+			name: "failure to run shuts the application down",
+			configure: func(r *recorder) []Component {
+				http := r.component("http")
+				http.Run = func(ctx context.Context) error {
+					return errBoom
+				}
+				return []Component{r.component("db"), http}
+			},
+			wantCalls: []string{"start db", "ping db", "start http", "ping http", "stop http", "stop db"},
+			wantErr:   errBoom,
+		},
+		{
+			name: "failure to stop is reported after stopping the others",
+			configure: func(r *recorder) []Component {
+				http := r.component("http")
+				http.Stop = func(ctx context.Context) error {
+					r.calls = append(r.calls, "stop http")
+					return errBoom
+				}
+				return []Component{r.component("db"), http}
+			},
+			interrupt: true,
+			wantCalls: []string{"start db", "ping db", "start http", "ping http", "stop http", "stop db"},
+			wantErr:   errBoom,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			m := New(time.Second)
+			m.Add(tt.configure(r)...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.interrupt {
+				cancel()
+			}
+			defer cancel()
+
+			err := m.Run(ctx)
+			assert.Equal(t, tt.wantCalls, r.calls)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestManager_Run_StopsRunningComponents(t *testing.T) {
+	m := New(time.Second)
+	stopped := make(chan struct{})
+	m.Add(Component{
+		Name: "worker",
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			close(stopped)
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	assert.NoError(t, m.Run(ctx))
+	select {
+	case <-stopped:
+	default:
+		t.Error("worker was not stopped")
+	}
+}
+
+func TestManager_Run_StopTimeout(t *testing.T) {
+	m := New(10 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	m.Add(Component{
+		Name: "stuck",
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, m.Run(ctx), context.DeadlineExceeded)
+}
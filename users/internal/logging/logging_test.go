@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNew(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+
+	var buf bytes.Buffer
+	l, err := New(&buf, FormatJSON, level)
+	assert.NoError(t, err)
+
+	l.Info("hidden")
+	level.Set(slog.LevelInfo)
+	l.Info("shown")
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "shown", entry["msg"])
+
+	_, err = New(&buf, "xml", level)
+	assert.Error(t, err)
+}
+
+func TestFromContext(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	assert.Same(t, slog.Default(), FromContext(context.Background()))
+	assert.Same(t, l, FromContext(NewContext(context.Background(), l)))
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		path      string
+		wantLevel string
+		wantRoute string
+		wantCode  float64
+		wantBytes float64
+	}{
+		{
+			name: "success",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				FromContext(r.Context()).Info("handling")
+				w.Write([]byte("hello"))
+			},
+			path:      "/users/42",
+			wantLevel: "INFO",
+			wantRoute: "/users/{id}",
+			wantCode:  http.StatusOK,
+			wantBytes: 5,
+		},
+		{
+			name: "failure is logged as an error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				FromContext(r.Context()).Info("handling")
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			path:      "/users/42",
+			wantLevel: "ERROR",
+			wantRoute: "/users/{id}",
+			wantCode:  http.StatusInternalServerError,
+		},
+		{
+			name: "root of a sub-router",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				FromContext(r.Context()).Info("handling")
+			},
+			path:      "/users/",
+			wantLevel: "INFO",
+			wantRoute: "/users",
+			wantCode:  http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, _ := New(&buf, FormatJSON, new(slog.LevelVar))
+
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Use(Middleware(l))
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/", tt.handler)
+				r.Get("/{id}", tt.handler)
+			})
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+
+			dec := json.NewDecoder(&buf)
+			var handling, access map[string]any
+			assert.NoError(t, dec.Decode(&handling))
+			assert.NoError(t, dec.Decode(&access))
+
+			assert.Equal(t, "handling", handling["msg"])
+			assert.NotEmpty(t, handling["request_id"])
+			assert.Equal(t, handling["request_id"], access["request_id"])
+
+			assert.Equal(t, "request", access["msg"])
+			assert.Equal(t, tt.wantLevel, access["level"])
+			assert.Equal(t, "GET", access["method"])
+			assert.Equal(t, tt.path, access["path"])
+			assert.Equal(t, tt.wantRoute, access["route"])
+			assert.Equal(t, "192.0.2.1:1234", access["remote_ip"])
+			assert.Equal(t, tt.wantCode, access["status"])
+			assert.Equal(t, tt.wantBytes, access["bytes"])
+			assert.Contains(t, access, "latency")
+		})
+	}
+}
+
+func TestNew_TraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := New(&buf, FormatJSON, new(slog.LevelVar))
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	l.With("component", "test").InfoContext(ctx, "traced")
+	l.Info("untraced")
+
+	dec := json.NewDecoder(&buf)
+	var traced, untraced map[string]any
+	assert.NoError(t, dec.Decode(&traced))
+	assert.NoError(t, dec.Decode(&untraced))
+
+	assert.Equal(t, span.SpanContext().TraceID().String(), traced["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), traced["span_id"])
+	assert.Equal(t, "test", traced["component"])
+	assert.NotContains(t, untraced, "trace_id")
+}
@@ -0,0 +1,366 @@
+// Package migrate applies and rolls back SQL migrations written in the dbmate
+// format. Applied versions are recorded in the schema_migrations table that
+// dbmate uses, so databases migrated by dbmate keep working.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/admarc/users/pkg/dbcollector"
+)
+
+const (
+	upMarker   = "-- migrate:up"
+	downMarker = "-- migrate:down"
+)
+
+// SchemaBehindErr is returned by Check when migrations are pending.
+var SchemaBehindErr = errors.New("database schema is behind")
+
+// Migration is a single migration file.
+type Migration struct {
+	// Version is the numeric prefix of the file name.
+	Version string
+	// Name is the rest of the file name without extension.
+	Name string
+	Up   string
+	Down string
+}
+
+// Status tells whether a migration has been applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Load reads the migrations named <version>_<name>.sql in dir of fsys, ordered
+// by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for _, f := range files {
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", f, err)
+		}
+		m, err := parse(path.Base(f), string(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration %s: %w", f, err)
+		}
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %s", migrations[i].Version)
+		}
+	}
+
+	return migrations, nil
+}
+
+func parse(file, content string) (Migration, error) {
+	version, name, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), "_")
+	if !ok || version == "" || strings.Trim(version, "0123456789") != "" {
+		return Migration{}, errors.New("file name must look like <version>_<name>.sql")
+	}
+
+	m := Migration{Version: version, Name: name}
+	var section *string
+	var b strings.Builder
+	flush := func() {
+		if section != nil {
+			*section = strings.TrimSpace(b.String())
+		}
+		b.Reset()
+	}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		// dbmate allows options such as transaction:false after a marker.
+		switch trimmed := strings.TrimSpace(line); {
+		case strings.HasPrefix(trimmed, upMarker):
+			flush()
+			section = &m.Up
+		case strings.HasPrefix(trimmed, downMarker):
+			flush()
+			section = &m.Down
+		default:
+			b.WriteString(line)
+		}
+	}
+	flush()
+
+	if m.Up == "" {
+		return Migration{}, fmt.Errorf("missing %q section", upMarker)
+	}
+	return m, nil
+}
+
+// Migrator applies migrations to a SQLite or PostgreSQL database.
+type Migrator struct {
+	db          *sql.DB
+	migrations  []Migration
+	lockTimeout time.Duration
+	// lock begins the transaction of a migration on conn, holding a lock
+	// that keeps other instances from migrating at the same time. reset
+	// undoes the settings it changed on conn, once the transaction ended.
+	lock func(ctx context.Context, conn *sql.Conn, timeout time.Duration) (reset func(), err error)
+}
+
+// New returns a Migrator for migrations of a SQLite database. While
+// migrating it holds the write lock of the database, waiting up to
+// lockTimeout for another instance to release it.
+func New(db *sql.DB, migrations []Migration, lockTimeout time.Duration) Migrator {
+	return Migrator{db: db, migrations: migrations, lockTimeout: lockTimeout, lock: lockSQLite}
+}
+
+// NewPostgres returns a Migrator for migrations of a PostgreSQL database.
+// While migrating it holds an advisory lock, waiting up to lockTimeout for
+// another instance to release it.
+func NewPostgres(db *sql.DB, migrations []Migration, lockTimeout time.Duration) Migrator {
+	return Migrator{db: db, migrations: migrations, lockTimeout: lockTimeout, lock: lockPostgres}
+}
+
+// Up applies every pending migration in order and returns the ones it
+// applied.
+func (m Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	for _, mig := range m.migrations {
+		ok, err := m.apply(ctx, mig)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, mig)
+		}
+	}
+	return applied, nil
+}
+
+// Down rolls back the latest applied migration and returns it. It returns
+// false when no migration is applied.
+func (m Migrator) Down(ctx context.Context) (Migration, bool, error) {
+	var rolledBack Migration
+	var ok bool
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if !applied[mig.Version] {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %s has no %q section", mig.Version, downMarker)
+			}
+			if _, err := conn.ExecContext(dbcollector.WithQueryName(ctx, "migrate.down"), mig.Down); err != nil {
+				return fmt.Errorf("failed to roll back migration %s: %w", mig.Version, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version); err != nil {
+				return fmt.Errorf("failed to unrecord migration %s: %w", mig.Version, err)
+			}
+			rolledBack, ok = mig, true
+			return nil
+		}
+		return nil
+	})
+	return rolledBack, ok, err
+}
+
+// Status lists every known migration and whether it has been applied. It
+// only reads, so that it works on read-only databases and takes no lock; a
+// database without schema_migrations has no migration applied.
+func (m Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := appliedVersions(ctx, m.db)
+	if isUndefinedTable(err) {
+		applied, err = map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		statuses = append(statuses, Status{Migration: mig, Applied: applied[mig.Version]})
+	}
+	return statuses, nil
+}
+
+// Check returns an error wrapping SchemaBehindErr when migrations are
+// pending. Like Status it only reads, health probes call it.
+func (m Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, s.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending migrations (%s)", SchemaBehindErr, len(pending), strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// apply runs mig unless it has been applied already.
+func (m Migrator) apply(ctx context.Context, mig Migration) (bool, error) {
+	var ok bool
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if applied[mig.Version] {
+			return nil
+		}
+		if _, err := conn.ExecContext(dbcollector.WithQueryName(ctx, "migrate.up"), mig.Up); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", mig.Version, err)
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", mig.Version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", mig.Version, err)
+		}
+		ok = true
+		return nil
+	})
+	return ok, err
+}
+
+// locked runs fn in a transaction holding the migration lock, so that only
+// one instance migrates at a time. The transaction commits if fn succeeds.
+func (m Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	reset, err := m.lock(ctx, conn, m.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer reset()
+	defer func() {
+		if err != nil {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+	}()
+
+	if err := createSchemaTable(ctx, conn); err != nil {
+		return err
+	}
+	if err := fn(conn); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", err)
+	}
+	return nil
+}
+
+// lockSQLite begins with BEGIN IMMEDIATE, which takes the write lock right
+// away instead of on the first write, waiting up to busy_timeout for other
+// writers. The connection goes back to the pool afterwards, so its own
+// busy_timeout is restored.
+func lockSQLite(ctx context.Context, conn *sql.Conn, timeout time.Duration) (func(), error) {
+	var busyTimeout int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+		return nil, fmt.Errorf("failed to read lock timeout: %w", err)
+	}
+	reset := func() {
+		conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA busy_timeout = %d", busyTimeout))
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", timeout.Milliseconds())); err != nil {
+		return nil, fmt.Errorf("failed to set lock timeout: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		reset()
+		return nil, fmt.Errorf("failed to lock database: %w", err)
+	}
+	return reset, nil
+}
+
+// postgresLockKey identifies the advisory lock of the migrations, it is the
+// same for every schema of the server.
+const postgresLockKey = 4_617_356_118_211_903_552
+
+// lockPostgres takes an advisory lock released when the transaction ends.
+// The lock timeout only applies to the transaction, so there is nothing to
+// reset.
+func lockPostgres(ctx context.Context, conn *sql.Conn, timeout time.Duration) (func(), error) {
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		return nil, fmt.Errorf("failed to begin migration: %w", err)
+	}
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("SET LOCAL lock_timeout = %d", timeout.Milliseconds()))
+	if err == nil {
+		_, err = conn.ExecContext(ctx, fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", int64(postgresLockKey)))
+	}
+	if err != nil {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		return nil, fmt.Errorf("failed to lock database: %w", err)
+	}
+	return func() {}, nil
+}
+
+func createSchemaTable(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version varchar(128) PRIMARY KEY)"); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// isUndefinedTable reports whether err is the error of SQLite or PostgreSQL
+// for a missing table.
+func isUndefinedTable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return state.SQLState() == "42P01"
+	}
+	return strings.Contains(err.Error(), "no such table")
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, q queryer) (map[string]bool, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[v] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return applied, nil
+}
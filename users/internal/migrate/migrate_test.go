@@ -0,0 +1,254 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/admarc/users/db/migrations"
+	"github.com/admarc/users/internal/storage/pgtest"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			name: "success",
+			fsys: fstest.MapFS{
+				"m/002_second.sql": {Data: []byte("-- migrate:up\nCREATE TABLE b (id int);\n\n-- migrate:down\nDROP TABLE b;\n")},
+				"m/001_first.sql":  {Data: []byte("-- migrate:up transaction:false\nCREATE TABLE a (id int);\n")},
+				"m/README.md":      {Data: []byte("not a migration")},
+			},
+			want: []Migration{
+				{Version: "001", Name: "first", Up: "CREATE TABLE a (id int);"},
+				{Version: "002", Name: "second", Up: "CREATE TABLE b (id int);", Down: "DROP TABLE b;"},
+			},
+		},
+		{
+			name: "failure - bad file name",
+			fsys: fstest.MapFS{
+				"m/first.sql": {Data: []byte("-- migrate:up\nSELECT 1;\n")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failure - missing up section",
+			fsys: fstest.MapFS{
+				"m/001_first.sql": {Data: []byte("-- migrate:down\nSELECT 1;\n")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failure - duplicate version",
+			fsys: fstest.MapFS{
+				"m/001_first.sql":  {Data: []byte("-- migrate:up\nSELECT 1;\n")},
+				"m/001_second.sql": {Data: []byte("-- migrate:up\nSELECT 1;\n")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys, "m")
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	ms, err := Load(migrations.FS, ".")
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+
+	m := New(newDB(t, filepath.Join(t.TempDir(), "db.sqlite3")), ms, time.Second)
+
+	assert.ErrorIs(t, m.Check(ctx), SchemaBehindErr)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ms, applied)
+	assert.NoError(t, m.Check(ctx))
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// Every down section undoes its up section.
+	for i := len(ms) - 1; i >= 0; i-- {
+		rolledBack, ok, err := m.Down(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, ms[i].Version, rolledBack.Version)
+	}
+	_, ok, err := m.Down(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.False(t, s.Applied, s.Version)
+	}
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ms, applied)
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+
+	ms := []Migration{
+		{Version: "001", Name: "first", Up: "CREATE TABLE a (id int);"},
+		{Version: "002", Name: "broken", Up: "CREATE TABLE b (id int); INSERT INTO missing VALUES (1);"},
+	}
+	db := newDB(t, filepath.Join(t.TempDir(), "db.sqlite3"))
+	m := New(db, ms, time.Second)
+
+	applied, err := m.Up(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, ms[:1], applied)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master WHERE name = 'b'").Scan(&n))
+	assert.Equal(t, 0, n)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Status{{Migration: ms[0], Applied: true}, {Migration: ms[1], Applied: false}}, statuses)
+}
+
+func TestMigrator_RestoresBusyTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	ms := []Migration{
+		{Version: "001", Name: "first", Up: "CREATE TABLE a (id int);"},
+		{Version: "002", Name: "broken", Up: "INSERT INTO missing VALUES (1);"},
+	}
+	db := newDB(t, "file:"+filepath.Join(t.TempDir(), "db.sqlite3")+"?_busy_timeout=250")
+	db.SetMaxOpenConns(1)
+
+	_, err := New(db, ms, 10*time.Second).Up(ctx)
+	assert.Error(t, err)
+
+	var busyTimeout int
+	require.NoError(t, db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout))
+	assert.Equal(t, 250, busyTimeout, "the pooled connection keeps its own busy_timeout")
+}
+
+func TestMigrator_Concurrent(t *testing.T) {
+	ctx := context.Background()
+
+	ms, err := Load(migrations.FS, ".")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+
+	// Each instance has its own pool, as separate processes would.
+	var wg sync.WaitGroup
+	counts := make([]int, 4)
+	errs := make([]error, 4)
+	for i := range counts {
+		m := New(newDB(t, path), ms, 10*time.Second)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied, err := m.Up(ctx)
+			counts[i], errs[i] = len(applied), err
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range counts {
+		assert.NoError(t, errs[i])
+		total += counts[i]
+	}
+	assert.Equal(t, len(ms), total)
+}
+
+func TestMigrator_CheckReadOnly(t *testing.T) {
+	ctx := context.Background()
+
+	ms, err := Load(migrations.FS, ".")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	rw := newDB(t, path)
+	_, err = rw.Exec("CREATE TABLE other (id int)")
+	require.NoError(t, err)
+	ro := newDB(t, "file:"+path+"?mode=ro")
+
+	assert.ErrorIs(t, New(ro, ms, time.Second).Check(ctx), SchemaBehindErr)
+	var n int
+	require.NoError(t, rw.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'schema_migrations'").Scan(&n))
+	assert.Equal(t, 0, n, "checking doesn't create schema_migrations")
+
+	_, err = New(rw, ms, time.Second).Up(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, New(ro, ms, time.Second).Check(ctx))
+	assert.ErrorIs(t, New(ro, append(ms, Migration{Version: "99990101000000", Name: "next", Up: "SELECT 1"}), time.Second).Check(ctx), SchemaBehindErr)
+}
+
+func TestMigrator_Postgres(t *testing.T) {
+	ctx := context.Background()
+
+	ms, err := Load(migrations.Postgres, "postgres")
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+
+	db := pgtest.NewDB(t)
+	m := NewPostgres(db, ms, time.Second)
+
+	assert.ErrorIs(t, m.Check(ctx), SchemaBehindErr)
+	var tables int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'").Scan(&tables))
+	assert.Equal(t, 0, tables, "checking doesn't create schema_migrations")
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ms, applied)
+	assert.NoError(t, m.Check(ctx))
+
+	for i := len(ms) - 1; i >= 0; i-- {
+		rolledBack, ok, err := m.Down(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, ms[i].Version, rolledBack.Version)
+	}
+
+	// A failed migration leaves nothing behind.
+	broken := append(ms[:len(ms):len(ms)], Migration{Version: "99990101000000", Name: "broken", Up: "CREATE TABLE b (id int); INSERT INTO missing VALUES (1);"})
+	applied, err = NewPostgres(db, broken, time.Second).Up(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, ms, applied)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'b'").Scan(&n))
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// UsersCursor is the position of the last user on a page. It is handed to
+// clients as an opaque token and only has meaning for the sort it was
+// produced with.
+type UsersCursor struct {
+	SortBy    UsersSortField `json:"s"`
+	Desc      bool           `json:"d,omitempty"`
+	ID        string         `json:"i"`
+	Name      string         `json:"n,omitempty"`
+	CreatedAt time.Time      `json:"c,omitempty"`
+}
+
+func NewUsersCursor(params ListUsersParams, last User) UsersCursor {
+	c := UsersCursor{SortBy: params.SortBy, Desc: params.Desc, ID: last.ID}
+	switch params.SortBy {
+	case UsersSortName:
+		c.Name = last.Name
+	default:
+		c.CreatedAt = last.CreatedAt
+	}
+	return c
+}
+
+func (c UsersCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeUsersCursor parses a token produced by Encode and checks that it was
+// issued for the same sort as params.
+func DecodeUsersCursor(token string, params ListUsersParams) (UsersCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return UsersCursor{}, fmt.Errorf("failed to decode cursor: %w", invalidCursor())
+	}
+
+	var c UsersCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return UsersCursor{}, fmt.Errorf("failed to unmarshal cursor: %w", invalidCursor())
+	}
+
+	if c.ID == "" || c.SortBy != params.SortBy || c.Desc != params.Desc {
+		return UsersCursor{}, fmt.Errorf("cursor does not match requested sort: %w", invalidCursor())
+	}
+
+	return c, nil
+}
+
+func invalidCursor() *Error {
+	return InvalidFieldErr(InvalidCursorErr, "cursor", "invalid", "cursor is malformed or was issued for another sort")
+}
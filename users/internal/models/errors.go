@@ -3,8 +3,88 @@ package models
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var InvalidErr = errors.New("invalid argument")
 
+// NotFoundErr is returned when the requested entity doesn't exist.
+var NotFoundErr = errors.New("not found")
+
+// VersionMismatchErr is returned when a write is conditioned on a version of
+// a user that is no longer current.
+var VersionMismatchErr = errors.New("version mismatch")
+
 var UserCreateParamInvalidNameErr = fmt.Errorf("invalid name: %w", InvalidErr)
+
+var UserCreateParamInvalidEmailErr = fmt.Errorf("invalid email: %w", InvalidErr)
+
+var UserCreateParamInvalidStatusErr = fmt.Errorf("invalid status: %w", InvalidErr)
+
+var UserUpdateParamInvalidNameErr = fmt.Errorf("invalid name: %w", InvalidErr)
+
+var UserUpdateParamInvalidEmailErr = fmt.Errorf("invalid email: %w", InvalidErr)
+
+// IllegalTransitionErr is returned when a user can't move from its current
+// status to the requested one.
+var IllegalTransitionErr = errors.New("illegal status transition")
+
+// UserEmailTakenErr is returned when another user already has the email.
+var UserEmailTakenErr = errors.New("email already in use")
+
+var UserUpdateParamImmutableIDErr = fmt.Errorf("id can not be changed: %w", InvalidErr)
+
+var ListUsersParamInvalidLimitErr = fmt.Errorf("invalid limit: %w", InvalidErr)
+
+var ListUsersParamInvalidSortErr = fmt.Errorf("invalid sort: %w", InvalidErr)
+
+var InvalidCursorErr = fmt.Errorf("invalid cursor: %w", InvalidErr)
+
+var PurgeParamInvalidRetentionErr = fmt.Errorf("invalid retention: %w", InvalidErr)
+
+// Error describes a failure in terms a client can act on. It wraps one of
+// the sentinel errors above so errors.Is keeps working on it.
+type Error struct {
+	// Code is a stable machine readable identifier such as "invalid_argument".
+	Code string
+	// Message is a human readable explanation safe to show to clients.
+	Message string
+	// Fields lists the offending input fields, if any.
+	Fields []FieldError
+	// Retryable tells whether repeating the same request may succeed.
+	Retryable bool
+	Err       error
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "; %s: %s", f.Field, f.Message)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %s", e.Err)
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// InvalidFieldErr builds an Error for a single invalid input field wrapping
+// err, which is expected to wrap InvalidErr.
+func InvalidFieldErr(err error, field, code, message string) *Error {
+	return &Error{
+		Code:    "invalid_argument",
+		Message: "request contains invalid fields",
+		Fields:  []FieldError{{Field: field, Code: code, Message: message}},
+		Err:     err,
+	}
+}
@@ -1,6 +1,68 @@
 package models
 
+import "time"
+
 type User struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	GivenName  string     `json:"given_name,omitempty"`
+	FamilyName string     `json:"family_name,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	Status     UserStatus `json:"status"`
+	Version    int64      `json:"version"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// AnyVersion conditions a write on whatever version of the user is current,
+// as If-Match: * does. Stored versions start at 1.
+const AnyVersion int64 = 0
+
+// UserStatus is the lifecycle state of a user.
+type UserStatus string
+
+const (
+	UserStatusPending   UserStatus = "pending"
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+	UserStatusLocked    UserStatus = "locked"
+	UserStatusDeleted   UserStatus = "deleted"
+)
+
+// StatusChange tells who asked for a status change and why.
+type StatusChange struct {
+	Actor  string
+	Reason string
+}
+
+// UserTransition records a change of the status of a user.
+type UserTransition struct {
+	UserID    string     `json:"user_id"`
+	From      UserStatus `json:"from"`
+	To        UserStatus `json:"to"`
+	Actor     string     `json:"actor"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type UsersSortField string
+
+const (
+	UsersSortCreatedAt UsersSortField = "created_at"
+	UsersSortName      UsersSortField = "name"
+)
+
+type ListUsersParams struct {
+	NamePrefix     string
+	SortBy         UsersSortField
+	Desc           bool
+	Limit          int
+	Cursor         string
+	IncludeDeleted bool
+}
+
+type UsersPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
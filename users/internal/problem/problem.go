@@ -0,0 +1,158 @@
+// Package problem renders errors as RFC 7807 application/problem+json
+// responses. The mapping from errors to HTTP status codes lives here so that
+// every handler answers the same failure the same way.
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/internal/models"
+	"github.com/go-chi/chi/middleware"
+)
+
+const ContentType = "application/problem+json"
+
+// Errors for failures of the HTTP exchange itself rather than of the domain.
+var (
+	MalformedBodyErr        = errors.New("malformed request body")
+	PayloadTooLargeErr      = errors.New("request body too large")
+	UnsupportedMediaTypeErr = errors.New("unsupported media type")
+	PreconditionRequiredErr = errors.New("precondition required")
+	PreconditionFailedErr   = errors.New("precondition failed")
+	UnprocessableErr        = errors.New("request can't be processed")
+	ConflictErr             = errors.New("conflicting request in progress")
+	UnauthorizedErr         = errors.New("unauthorized")
+)
+
+// Detailed wraps one of the errors above with a detail message that is shown
+// to the client.
+func Detailed(err error, detail string) error {
+	return &models.Error{Message: detail, Err: err}
+}
+
+// DecodeErr describes a failure to read or decode a JSON request body in
+// terms of the errors above.
+func DecodeErr(err error) error {
+	var maxErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxErr):
+		return Detailed(PayloadTooLargeErr, fmt.Sprintf("body must not be larger than %d bytes", maxErr.Limit))
+	case errors.Is(err, io.EOF):
+		return Detailed(MalformedBodyErr, "body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return Detailed(MalformedBodyErr, "body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return Detailed(MalformedBodyErr, fmt.Sprintf("body contains malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return models.InvalidFieldErr(models.InvalidErr, typeErr.Field, "type", "must be of type "+typeErr.Type.String())
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return models.InvalidFieldErr(models.InvalidErr, field, "unknown", "field is not allowed")
+	default:
+		return Detailed(MalformedBodyErr, err.Error())
+	}
+}
+
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      string              `json:"code"`
+	RequestID string              `json:"request_id,omitempty"`
+	Retryable bool                `json:"retryable"`
+	Errors    []models.FieldError `json:"errors,omitempty"`
+}
+
+// StatusClientClosedRequest is the non-standard status nginx uses for
+// requests the client gave up on before a response was written.
+const StatusClientClosedRequest = 499
+
+type mapping struct {
+	err       error
+	status    int
+	code      string
+	retryable bool
+}
+
+// mappings is checked in order with errors.Is, so more specific errors have
+// to come before the ones they wrap.
+var mappings = []mapping{
+	{models.NotFoundErr, http.StatusNotFound, "not_found", false},
+	{models.VersionMismatchErr, http.StatusPreconditionFailed, "version_mismatch", false},
+	{models.UserEmailTakenErr, http.StatusConflict, "email_taken", false},
+	{models.IllegalTransitionErr, http.StatusConflict, "illegal_transition", false},
+	{models.InvalidCursorErr, http.StatusBadRequest, "invalid_cursor", false},
+	{models.InvalidErr, http.StatusBadRequest, "invalid_argument", false},
+	{MalformedBodyErr, http.StatusBadRequest, "malformed_body", false},
+	{PayloadTooLargeErr, http.StatusRequestEntityTooLarge, "payload_too_large", false},
+	{UnsupportedMediaTypeErr, http.StatusUnsupportedMediaType, "unsupported_media_type", false},
+	{PreconditionRequiredErr, http.StatusPreconditionRequired, "precondition_required", false},
+	{PreconditionFailedErr, http.StatusPreconditionFailed, "precondition_failed", false},
+	{UnprocessableErr, http.StatusUnprocessableEntity, "unprocessable", false},
+	{ConflictErr, http.StatusConflict, "conflict", false},
+	{UnauthorizedErr, http.StatusUnauthorized, "unauthorized", false},
+	{context.Canceled, StatusClientClosedRequest, "client_closed_request", true},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, "timeout", true},
+}
+
+// New builds the problem document describing err. Details of errors that
+// are not part of the mapping are not exposed.
+func New(r *http.Request, err error) Problem {
+	p := Problem{
+		Type:      "about:blank",
+		Status:    http.StatusInternalServerError,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+
+	p.Code = "internal_server_error"
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			p.Status, p.Code, p.Detail, p.Retryable = m.status, m.code, m.err.Error(), m.retryable
+			break
+		}
+	}
+
+	var de *models.Error
+	if errors.As(err, &de) {
+		if de.Code != "" {
+			p.Code = de.Code
+		}
+		p.Detail = de.Message
+		p.Errors = de.Fields
+		p.Retryable = de.Retryable
+	}
+
+	p.Title = http.StatusText(p.Status)
+	if p.Status == StatusClientClosedRequest {
+		p.Title = "Client Closed Request"
+	}
+
+	return p
+}
+
+// Write renders err as a problem document. Server errors are logged with
+// the request logger since their details are not sent to the client.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := New(r, err)
+
+	if p.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "status", p.Status, "error", err)
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
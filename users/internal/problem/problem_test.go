@@ -0,0 +1,112 @@
+package problem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users", nil)
+	r = r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, "host/abc-000001"))
+
+	tests := []struct {
+		name string
+		err  error
+		want Problem
+	}{
+		{
+			name: "domain error with fields",
+			err: fmt.Errorf("failed to create user: %w",
+				models.InvalidFieldErr(models.UserCreateParamInvalidNameErr, "name", "required", "name must not be empty")),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Bad Request",
+				Status:    http.StatusBadRequest,
+				Detail:    "request contains invalid fields",
+				Instance:  "/users",
+				Code:      "invalid_argument",
+				RequestID: "host/abc-000001",
+				Errors:    []models.FieldError{{Field: "name", Code: "required", Message: "name must not be empty"}},
+			},
+		},
+		{
+			name: "mapped sentinel",
+			err:  fmt.Errorf("failed to update user: %w", models.VersionMismatchErr),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Precondition Failed",
+				Status:    http.StatusPreconditionFailed,
+				Detail:    "version mismatch",
+				Instance:  "/users",
+				Code:      "version_mismatch",
+				RequestID: "host/abc-000001",
+			},
+		},
+		{
+			name: "not found",
+			err:  fmt.Errorf("failed to get user: %w", models.NotFoundErr),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "not found",
+				Instance:  "/users",
+				Code:      "not_found",
+				RequestID: "host/abc-000001",
+			},
+		},
+		{
+			name: "client went away",
+			err:  fmt.Errorf("failed to get user: %w", context.Canceled),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Client Closed Request",
+				Status:    StatusClientClosedRequest,
+				Detail:    "context canceled",
+				Instance:  "/users",
+				Code:      "client_closed_request",
+				RequestID: "host/abc-000001",
+				Retryable: true,
+			},
+		},
+		{
+			name: "timeout",
+			err:  fmt.Errorf("failed to get user: %w", context.DeadlineExceeded),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Service Unavailable",
+				Status:    http.StatusServiceUnavailable,
+				Detail:    "context deadline exceeded",
+				Instance:  "/users",
+				Code:      "timeout",
+				RequestID: "host/abc-000001",
+				Retryable: true,
+			},
+		},
+		{
+			name: "unknown errors don't leak details",
+			err:  errors.New("database is locked"),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Instance:  "/users",
+				Code:      "internal_server_error",
+				RequestID: "host/abc-000001",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, New(r, tt.err))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package sqldb
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/pkg/dbcollector"
+)
+
+// RetryPolicy says how often and how fast statements failing with a
+// transient error are run again. Delays grow exponentially from BaseDelay up
+// to MaxDelay with full jitter, so that competing writers spread out. A
+// MaxAttempts of one or less disables retries.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// delay returns how long to wait before the attempt after attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		d = p.BaseDelay << shift
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retry runs fn until it doesn't fail with SQLITE_BUSY or SQLITE_LOCKED,
+// giving up when out of attempts or when the next attempt would start after
+// the deadline of ctx. Statements in a transaction are never retried on
+// their own: SQLite may need the whole transaction to be rolled back to make
+// progress.
+func (d *DB) retry(ctx context.Context, query string, fn func() error) error {
+	p := d.opts.Retry
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		code, ok := dbcollector.SQLiteBusy(err)
+		if !ok || p.MaxAttempts <= 1 {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			d.giveUp(ctx, query, "attempts", attempt, err)
+			return err
+		}
+
+		delay := p.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			d.giveUp(ctx, query, "deadline", attempt, err)
+			return err
+		}
+		if d.opts.Retries != nil {
+			d.opts.Retries.Retry(ctx, query, code)
+		}
+		logging.FromContext(ctx).DebugContext(ctx, "retrying statement", "statement", query, "attempt", attempt, "delay", delay, "error", err)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			d.giveUp(ctx, query, "deadline", attempt, err)
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (d *DB) giveUp(ctx context.Context, query, reason string, attempts int, err error) {
+	if d.opts.Retries != nil {
+		d.opts.Retries.GiveUp(ctx, query, reason)
+	}
+	logging.FromContext(ctx).WarnContext(ctx, "gave up retrying statement", "statement", query, "reason", reason, "attempts", attempts, "error", err)
+}
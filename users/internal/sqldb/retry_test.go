@@ -0,0 +1,167 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/pkg/dbcollector"
+	"github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 4: 50 * time.Millisecond, 40: 50 * time.Millisecond} {
+		for i := 0; i < 100; i++ {
+			d := p.delay(attempt)
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+			assert.LessOrEqual(t, d, max)
+		}
+	}
+}
+
+func TestDB_retry(t *testing.T) {
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		errs         []error
+		timeout      time.Duration
+		wantErr      error
+		wantAttempts int
+		wantRetries  float64
+		wantGiveUp   string
+	}{
+		{
+			name:         "success after transient errors",
+			policy:       policy,
+			errs:         []error{busy, busy, nil},
+			wantAttempts: 3,
+			wantRetries:  2,
+		},
+		{
+			name:         "other errors are not retried",
+			policy:       policy,
+			errs:         []error{sql.ErrNoRows},
+			wantErr:      sql.ErrNoRows,
+			wantAttempts: 1,
+		},
+		{
+			name:         "out of attempts",
+			policy:       policy,
+			errs:         []error{busy, busy, busy},
+			wantErr:      busy,
+			wantAttempts: 3,
+			wantRetries:  2,
+			wantGiveUp:   "attempts",
+		},
+		{
+			name:         "next attempt after the deadline",
+			policy:       RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour},
+			errs:         []error{busy, nil},
+			timeout:      time.Second,
+			wantErr:      busy,
+			wantAttempts: 1,
+			wantGiveUp:   "deadline",
+		},
+		{
+			name:         "disabled",
+			errs:         []error{busy, nil},
+			wantErr:      busy,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			m := dbcollector.NewRetryCollector("test", "db", "sqlite")
+			d := New(nil, Options{Retry: tt.policy, Retries: m})
+
+			attempts := 0
+			err := d.retry(dbcollector.WithQueryName(ctx, "users.create"), "INSERT", func() error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+			assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+			assert.Equal(t, tt.wantAttempts, attempts)
+			assert.Equal(t, tt.wantRetries, testutil.ToFloat64(m.Retries.WithLabelValues("users.create", "busy")))
+			giveUps := 0
+			if tt.wantGiveUp != "" {
+				giveUps = 1
+				assert.Equal(t, 1.0, testutil.ToFloat64(m.GiveUps.WithLabelValues("users.create", tt.wantGiveUp)))
+			}
+			assert.Equal(t, giveUps, testutil.CollectAndCount(m.GiveUps))
+		})
+	}
+}
+
+func TestDB_retryLockedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	p := Pragmas{JournalMode: "wal", Synchronous: "normal"}
+	open := func() *sql.DB {
+		db := sql.OpenDB(Connector(&sqlite3.SQLiteDriver{ConnectHook: p.ConnectHook}, path))
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	locker := open()
+	_, err := locker.Exec("CREATE TABLE users (id TEXT)")
+	require.NoError(t, err)
+
+	tx, err := locker.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO users VALUES ('a')")
+	require.NoError(t, err)
+
+	// The lock is released once the first attempt failed, before the retry.
+	var commitErr error
+	ctx := logging.NewContext(context.Background(), slog.New(onRecord(func(r slog.Record) {
+		if r.Message == "retrying statement" && commitErr == nil {
+			commitErr = tx.Commit()
+		}
+	})))
+
+	m := dbcollector.NewRetryCollector("test", "db", "sqlite")
+	d := New(open(), Options{
+		Retry:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Retries: m,
+	})
+	_, err = d.ExecContext(ctx, "INSERT INTO users VALUES ('b')")
+	require.NoError(t, err)
+	require.NoError(t, commitErr)
+
+	var n int
+	require.NoError(t, d.QueryRowContext(ctx, "SELECT count(*) FROM users").Scan(&n))
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Retries.WithLabelValues("insert into users values (?)", "busy")))
+}
+
+// onRecord is a slog.Handler passing every record to its func.
+type onRecord func(r slog.Record)
+
+func (h onRecord) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h onRecord) Handle(_ context.Context, r slog.Record) error {
+	h(r)
+	return nil
+}
+
+func (h onRecord) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h onRecord) WithGroup(string) slog.Handler { return h }
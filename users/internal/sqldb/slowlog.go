@@ -0,0 +1,143 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/pkg/dbcollector"
+)
+
+// explainTimeout bounds the EXPLAIN QUERY PLAN of a slow statement, which
+// runs even when the statement's own context is done.
+const explainTimeout = time.Second
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// observe logs query when it took longer than the slow query threshold. The
+// log carries the request ID through the logger found in ctx. Arguments are
+// logged by type and size only, since they hold personal data. err is the
+// error the statement returned, a failed statement is not explained since
+// running it again would most likely be as slow and fail the same way.
+func (d *DB) observe(ctx context.Context, q queryer, query string, args []any, start time.Time, err error) {
+	took := time.Since(start)
+	if d.opts.SlowThreshold <= 0 || took < d.opts.SlowThreshold {
+		return
+	}
+
+	if d.opts.SlowQueries != nil {
+		d.opts.SlowQueries.Observe(ctx, query)
+	}
+
+	attrs := []any{
+		"statement", query,
+		"args", argShapes(args),
+		"duration", took,
+		"threshold", d.opts.SlowThreshold,
+		"caller", caller(),
+	}
+	if d.opts.Explain && err == nil && ctx.Err() == nil && explainable(query) {
+		plan, err := explain(ctx, q, query, args)
+		if err != nil {
+			attrs = append(attrs, "plan_error", err)
+		} else {
+			attrs = append(attrs, "plan", plan)
+		}
+	}
+	logging.FromContext(ctx).WarnContext(ctx, "slow query", attrs...)
+}
+
+// argShapes describes args without their values, like string(36) for a
+// 36 byte string.
+func argShapes(args []any) []string {
+	shapes := make([]string, len(args))
+	for i, a := range args {
+		name := ""
+		if n, ok := a.(sql.NamedArg); ok {
+			name, a = ":"+n.Name+"=", n.Value
+		}
+
+		var shape string
+		switch v := a.(type) {
+		case nil:
+			shape = "nil"
+		case string:
+			shape = fmt.Sprintf("string(%d)", len(v))
+		case []byte:
+			shape = fmt.Sprintf("[]byte(%d)", len(v))
+		default:
+			shape = fmt.Sprintf("%T", v)
+		}
+		shapes[i] = name + shape
+	}
+	return shapes
+}
+
+var pkgPath = reflect.TypeOf(DB{}).PkgPath()
+
+// caller returns the first function up the stack outside of this package
+// and database/sql, as file:line.
+func caller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPath+".") && !strings.HasPrefix(f.Function, "database/sql.") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// explainable reports whether query is a statement that has a query plan.
+func explainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "select", "insert", "update", "delete", "replace", "with":
+		return true
+	}
+	return false
+}
+
+// explain returns the EXPLAIN QUERY PLAN of query, one step per line
+// indented under its parent.
+func explain(ctx context.Context, q queryer, query string, args []any) (string, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), explainTimeout)
+	defer cancel()
+
+	rows, err := q.QueryContext(dbcollector.WithQueryName(ctx, "sqldb.explain"), "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	depth := map[int]int{}
+	var lines []string
+	for rows.Next() {
+		var (
+			id, parent, notUsed int
+			detail              string
+		)
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return "", fmt.Errorf("failed to scan query plan: %w", err)
+		}
+		depth[id] = depth[parent] + 1
+		lines = append(lines, strings.Repeat("  ", depth[id]-1)+detail)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+	return strings.Join(lines, "\n"), nil
+}
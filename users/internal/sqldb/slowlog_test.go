@@ -0,0 +1,33 @@
+package sqldb
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []any
+		want []string
+	}{
+		{
+			name: "values are redacted",
+			args: []any{"mike@example.com", []byte("abc"), int64(3), nil, time.Time{}},
+			want: []string{"string(16)", "[]byte(3)", "int64", "nil", "time.Time"},
+		},
+		{
+			name: "named",
+			args: []any{sql.Named("id", "a")},
+			want: []string{":id=string(1)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, argShapes(tt.args))
+		})
+	}
+}
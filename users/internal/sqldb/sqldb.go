@@ -0,0 +1,183 @@
+// Package sqldb wraps the SQLite and PostgreSQL connection pools for the
+// storage packages, running statements in the transaction carried by their
+// context, retrying those SQLite couldn't run because the database was
+// locked and logging the ones that take longer than a threshold.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/admarc/users/pkg/dbcollector"
+)
+
+// Options configures New.
+type Options struct {
+	// Postgres is set when db is a PostgreSQL pool. Explain, Retry and
+	// Tx.Immediate only apply to SQLite and are ignored then.
+	Postgres bool
+	// SlowThreshold is the duration above which statements are logged as
+	// slow, zero disables the slow query log.
+	SlowThreshold time.Duration
+	// Explain adds the EXPLAIN QUERY PLAN of slow statements to the log.
+	Explain bool
+	// SlowQueries counts slow statements when not nil.
+	SlowQueries *dbcollector.SlowQueryMetrics
+	// ReadOnly is a pool of read-only connections to the same database,
+	// used by DB.ReadOnly.
+	ReadOnly *sql.DB
+	// Retry is how statements failing with a transient error are retried.
+	Retry RetryPolicy
+	// Retries counts retries and give-ups when not nil.
+	Retries *dbcollector.RetryMetrics
+	// Tx are the options of the transactions started by InTx, and by
+	// BeginTx when given none.
+	Tx TxOptions
+}
+
+// DB runs statements on an *sql.DB, retries those failing with a transient
+// error outside of transactions and reports the slow ones. Statements run
+// with a context carrying a transaction started by InTx join it.
+type DB struct {
+	db       *sql.DB
+	opts     Options
+	readOnly *DB
+	// primary is the DB owning the transactions that d joins.
+	primary *DB
+}
+
+func New(db *sql.DB, opts Options) *DB {
+	if opts.Postgres {
+		opts.Explain = false
+		opts.Retry = RetryPolicy{}
+		opts.Tx.Immediate = false
+	}
+	d := &DB{db: db, opts: opts}
+	d.readOnly, d.primary = d, d
+	if opts.ReadOnly != nil {
+		opts.ReadOnly = nil
+		d.readOnly = &DB{db: d.opts.ReadOnly, opts: opts, primary: d}
+		d.readOnly.readOnly = d.readOnly
+	}
+	return d
+}
+
+// ReadOnly returns a DB running statements on the read-only pool, so that
+// reads don't queue behind writes for a connection. Without a read-only pool
+// it returns d.
+func (d *DB) ReadOnly() *DB {
+	return d.readOnly
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (res sql.Result, err error) {
+	if tx := d.txFrom(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	err = d.retry(ctx, query, func() error {
+		start := time.Now()
+		res, err = d.db.ExecContext(ctx, query, args...)
+		d.observe(ctx, d.db, query, args, start, err)
+		return err
+	})
+	return res, err
+}
+
+// QueryContext runs query. The statement is timed until the rows are
+// closed, since SQLite does most of the work while they are read. Only
+// errors starting the query are retried, not those met reading the rows.
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (rows *Rows, err error) {
+	if tx := d.txFrom(ctx); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	err = d.retry(ctx, query, func() error {
+		rows, err = d.query(ctx, d.db, query, args)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRowContext runs query when the row is scanned, retrying it as a
+// whole, since SQLite only runs statements like UPDATE ... RETURNING while
+// their rows are read.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	if tx := d.txFrom(ctx); tx != nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+	return &Row{scan: func(dest []any) error {
+		return d.retry(ctx, query, func() error {
+			rows, err := d.query(ctx, d.db, query, args)
+			return scanRow(rows, err, dest)
+		})
+	}}
+}
+
+func (d *DB) query(ctx context.Context, q queryer, query string, args []any) (*Rows, error) {
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		d.observe(ctx, q, query, args, start, err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, done: func(err error) { d.observe(ctx, q, query, args, start, err) }}, nil
+}
+
+// Rows are the result of a query. The query is timed until Next returns
+// false or Close is called.
+type Rows struct {
+	*sql.Rows
+	done func(err error)
+}
+
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.finish()
+	return false
+}
+
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.finish()
+	return err
+}
+
+func (r *Rows) finish() {
+	if r.done != nil {
+		r.done(r.Rows.Err())
+		r.done = nil
+	}
+}
+
+// Row is the result of QueryRowContext, behaving like *sql.Row except that
+// the query runs when the row is scanned.
+type Row struct {
+	scan func(dest []any) error
+}
+
+// Scan copies the first row into dest and returns sql.ErrNoRows when there
+// is none.
+func (r *Row) Scan(dest ...any) error {
+	return r.scan(dest)
+}
+
+// scanRow copies the first of rows, returned by a query with err, into
+// dest.
+func scanRow(rows *Rows, err error, dest []any) error {
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	return rows.Close()
+}
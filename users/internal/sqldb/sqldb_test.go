@@ -0,0 +1,181 @@
+package sqldb_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/internal/sqldb"
+	"github.com/admarc/users/pkg/dbcollector"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T, opts sqldb.Options) *sqldb.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT); INSERT INTO users VALUES ('a', 'mike'), ('b', 'anna')")
+	require.NoError(t, err)
+
+	return sqldb.New(db, opts)
+}
+
+func logs(buf *bytes.Buffer) []map[string]any {
+	var entries []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var e map[string]any
+		if dec.Decode(&e) != nil {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestDB_slowQueries(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, nil)).With("request_id", "req-1")
+	ctx := logging.NewContext(context.Background(), l)
+
+	metrics := dbcollector.NewSlowQueryCollector("test", "db", "sqlite")
+	db := newTestDB(t, sqldb.Options{SlowThreshold: time.Nanosecond, Explain: true, SlowQueries: metrics})
+
+	var name string
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT name FROM users WHERE name = ?", "mike").Scan(&name))
+	assert.Equal(t, "mike", name)
+
+	entries := logs(&buf)
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "slow query", e["msg"])
+	assert.Equal(t, "WARN", e["level"])
+	assert.Equal(t, "req-1", e["request_id"])
+	assert.Equal(t, "SELECT name FROM users WHERE name = ?", e["statement"])
+	assert.Equal(t, []any{"string(4)"}, e["args"])
+	assert.Contains(t, e["caller"], "sqldb_test.go:")
+	assert.Equal(t, "SCAN users", e["plan"])
+	assert.NotContains(t, buf.String(), "mike", "argument values are not logged")
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", "john", "a")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	entries = logs(&buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "UPDATE users SET name = ? WHERE id = ?", entries[0]["statement"])
+	assert.Equal(t, "SEARCH users USING INDEX sqlite_autoindex_users_1 (id=?)", entries[0]["plan"])
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics, "test_db_slow_queries_total"))
+}
+
+func TestDB_slowFailedQueries(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.NewContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	db := newTestDB(t, sqldb.Options{SlowThreshold: time.Nanosecond, Explain: true})
+
+	_, err := db.ExecContext(ctx, "INSERT INTO users VALUES (?, ?)", "a", "john")
+	assert.ErrorContains(t, err, "UNIQUE constraint failed")
+	_, err = db.QueryContext(cancelled, "SELECT name FROM users")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	entries := logs(&buf)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, "slow query", e["msg"])
+		assert.NotContains(t, e, "plan", "failed statements are not explained")
+		assert.NotContains(t, e, "plan_error")
+	}
+}
+
+func TestDB_slowQueriesPostgres(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.NewContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	// EXPLAIN QUERY PLAN is SQLite only, a PostgreSQL DB doesn't run it.
+	db := newTestDB(t, sqldb.Options{Postgres: true, SlowThreshold: time.Nanosecond, Explain: true})
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT name FROM users WHERE id = ?", "a").Scan(new(string)))
+
+	entries := logs(&buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "slow query", entries[0]["msg"])
+	assert.NotContains(t, entries[0], "plan")
+}
+
+func TestDB_fastQueries(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.NewContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	tests := []struct {
+		name string
+		opts sqldb.Options
+	}{
+		{name: "below threshold", opts: sqldb.Options{SlowThreshold: time.Hour, Explain: true}},
+		{name: "disabled", opts: sqldb.Options{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, tt.opts)
+
+			rows, err := db.QueryContext(ctx, "SELECT id FROM users ORDER BY id")
+			require.NoError(t, err)
+			var ids []string
+			for rows.Next() {
+				var id string
+				require.NoError(t, rows.Scan(&id))
+				ids = append(ids, id)
+			}
+			assert.Equal(t, []string{"a", "b"}, ids)
+
+			err = db.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ?", "c").Scan(new(string))
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+
+			assert.Empty(t, buf.String())
+		})
+	}
+}
+
+func TestDB_ReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	rw, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer rw.Close()
+	ro, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	require.NoError(t, err)
+	defer ro.Close()
+
+	_, err = rw.Exec("CREATE TABLE users (id TEXT)")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	db := sqldb.New(rw, sqldb.Options{ReadOnly: ro})
+	_, err = db.ExecContext(ctx, "INSERT INTO users VALUES ('a')")
+	assert.NoError(t, err)
+
+	var n int
+	assert.NoError(t, db.ReadOnly().QueryRowContext(ctx, "SELECT count(*) FROM users").Scan(&n))
+	assert.Equal(t, 1, n)
+	_, err = db.ReadOnly().ExecContext(ctx, "INSERT INTO users VALUES ('b')")
+	assert.Error(t, err, "statements run on the read-only pool")
+
+	same := sqldb.New(rw, sqldb.Options{})
+	assert.Same(t, same, same.ReadOnly())
+}
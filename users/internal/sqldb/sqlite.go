@@ -0,0 +1,94 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Pragmas tune SQLite connections. They are set on every connection by
+// ConnectHook as soon as the pool opens it.
+type Pragmas struct {
+	// JournalMode is delete, truncate, persist, memory, wal or off. It is
+	// left alone on QueryOnly connections, which can't change it.
+	JournalMode string
+	// Synchronous is off, normal, full or extra.
+	Synchronous string
+	BusyTimeout time.Duration
+	ForeignKeys bool
+	// CacheSize is in pages when positive and in KiB when negative.
+	CacheSize int
+	MmapSize  int64
+	// QueryOnly makes the connection refuse to write.
+	QueryOnly bool
+}
+
+var (
+	journalModes = map[string]bool{"delete": true, "truncate": true, "persist": true, "memory": true, "wal": true, "off": true}
+	syncLevels   = map[string]bool{"off": true, "normal": true, "full": true, "extra": true}
+)
+
+func (p Pragmas) Validate() error {
+	if !journalModes[p.JournalMode] {
+		return fmt.Errorf("unknown journal mode %q", p.JournalMode)
+	}
+	if !syncLevels[p.Synchronous] {
+		return fmt.Errorf("unknown synchronous level %q", p.Synchronous)
+	}
+	if p.BusyTimeout < 0 || p.MmapSize < 0 {
+		return fmt.Errorf("busy timeout and mmap size must not be negative")
+	}
+	return nil
+}
+
+// statements returns the PRAGMA statements setting p. The busy timeout
+// comes first so that switching the journal mode waits for other
+// connections instead of failing.
+func (p Pragmas) statements() []string {
+	stmts := []string{fmt.Sprintf("PRAGMA busy_timeout = %d", p.BusyTimeout.Milliseconds())}
+	if !p.QueryOnly {
+		stmts = append(stmts, "PRAGMA journal_mode = "+p.JournalMode)
+	}
+	stmts = append(stmts,
+		"PRAGMA synchronous = "+p.Synchronous,
+		fmt.Sprintf("PRAGMA foreign_keys = %t", p.ForeignKeys),
+		fmt.Sprintf("PRAGMA cache_size = %d", p.CacheSize),
+		fmt.Sprintf("PRAGMA mmap_size = %d", p.MmapSize),
+		fmt.Sprintf("PRAGMA query_only = %t", p.QueryOnly),
+	)
+	return stmts
+}
+
+// ConnectHook sets p on conn, to be used as sqlite3.SQLiteDriver.ConnectHook.
+// p must have been validated.
+func (p Pragmas) ConnectHook(conn *sqlite3.SQLiteConn) error {
+	for _, stmt := range p.statements() {
+		if _, err := conn.Exec(stmt, nil); err != nil {
+			return fmt.Errorf("failed to set %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// Connector returns a connector opening connections to dsn with d, for
+// sql.OpenDB. Unlike sql.Open it doesn't need d to be registered, so every
+// pool can have a driver with its own ConnectHook.
+func Connector(d driver.Driver, dsn string) driver.Connector {
+	return connector{driver: d, dsn: dsn}
+}
+
+type connector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,80 @@
+package sqldb
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPragmas = Pragmas{
+	JournalMode: "wal",
+	Synchronous: "normal",
+	BusyTimeout: 2 * time.Second,
+	ForeignKeys: true,
+	CacheSize:   -4000,
+	MmapSize:    1 << 20,
+}
+
+func TestPragmas_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(p *Pragmas)
+		wantErr string
+	}{
+		{name: "valid", modify: func(p *Pragmas) {}},
+		{name: "journal mode", modify: func(p *Pragmas) { p.JournalMode = "WAL2" }, wantErr: `unknown journal mode "WAL2"`},
+		{name: "synchronous", modify: func(p *Pragmas) { p.Synchronous = "" }, wantErr: `unknown synchronous level ""`},
+		{name: "negative mmap size", modify: func(p *Pragmas) { p.MmapSize = -1 }, wantErr: "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPragmas
+			tt.modify(&p)
+			err := p.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestPragmas_ConnectHook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	open := func(p Pragmas) *sql.DB {
+		db := sql.OpenDB(Connector(&sqlite3.SQLiteDriver{ConnectHook: p.ConnectHook}, path))
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	rw := open(testPragmas)
+	_, err := rw.Exec("CREATE TABLE users (id TEXT)")
+	require.NoError(t, err)
+
+	pragmas := map[string]any{}
+	for _, name := range []string{"journal_mode", "synchronous", "busy_timeout", "foreign_keys", "cache_size", "mmap_size", "query_only"} {
+		var v any
+		require.NoError(t, rw.QueryRow("PRAGMA "+name).Scan(&v))
+		pragmas[name] = v
+	}
+	assert.Equal(t, map[string]any{
+		"journal_mode": "wal",
+		"synchronous":  int64(1),
+		"busy_timeout": int64(2000),
+		"foreign_keys": int64(1),
+		"cache_size":   int64(-4000),
+		"mmap_size":    int64(1 << 20),
+		"query_only":   int64(0),
+	}, pragmas)
+
+	ro := testPragmas
+	ro.QueryOnly = true
+	_, err = open(ro).Exec("INSERT INTO users VALUES ('a')")
+	assert.ErrorContains(t, err, "readonly")
+}
@@ -0,0 +1,223 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TxOptions configures the transactions started by DB.BeginTx and DB.InTx.
+type TxOptions struct {
+	// Isolation is passed on to the driver. SQLite transactions are always
+	// serializable and ignore it.
+	Isolation sql.IsolationLevel
+	// ReadOnly transactions run on the read-only pool when there is one.
+	ReadOnly bool
+	// Immediate starts SQLite write transactions with BEGIN IMMEDIATE, so
+	// that they take the write lock up front and wait for it with the busy
+	// timeout instead of failing when they first write after a read.
+	Immediate bool
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"default":          sql.LevelDefault,
+	"read_uncommitted": sql.LevelReadUncommitted,
+	"read_committed":   sql.LevelReadCommitted,
+	"write_committed":  sql.LevelWriteCommitted,
+	"repeatable_read":  sql.LevelRepeatableRead,
+	"snapshot":         sql.LevelSnapshot,
+	"serializable":     sql.LevelSerializable,
+	"linearizable":     sql.LevelLinearizable,
+}
+
+// ParseIsolation returns the isolation level named like read_committed.
+func ParseIsolation(s string) (sql.IsolationLevel, error) {
+	level, ok := isolationLevels[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown isolation level %q", s)
+	}
+	return level, nil
+}
+
+type txKey struct{}
+
+// txFrom returns the transaction carried by ctx when d may join it, that is
+// when it was started on d, the DB d reads for or another read-only DB of
+// the same one.
+func (d *DB) txFrom(ctx context.Context) *Tx {
+	tx, _ := ctx.Value(txKey{}).(*Tx)
+	if tx == nil || tx.db.primary != d.primary {
+		return nil
+	}
+	return tx
+}
+
+// InTx runs fn in a transaction started with the options d was created
+// with. The statements run by d and its read-only DB with the context given
+// to fn join the transaction, which is committed when fn returns nil and
+// rolled back when it returns an error or panics. Calls nested in fn run in
+// the same transaction.
+func (d *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if d.txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back transaction: %w", rbErr))
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// BeginTx starts a transaction, with the options d was created with when
+// opts is nil. When ctx carries a transaction started by InTx the returned
+// Tx is part of it and its Commit and Rollback do nothing, leaving the
+// outcome to InTx.
+func (d *DB) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
+	if tx := d.txFrom(ctx); tx != nil {
+		return &Tx{q: tx.q, db: tx.db}, nil
+	}
+	if opts == nil {
+		opts = &d.opts.Tx
+	}
+	if opts.ReadOnly && d.readOnly != d {
+		return d.readOnly.BeginTx(ctx, &TxOptions{Isolation: opts.Isolation, ReadOnly: true})
+	}
+	if opts.Immediate && !opts.ReadOnly {
+		return d.beginImmediate(ctx)
+	}
+
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Tx{q: tx, db: d, commit: tx.Commit, rollback: tx.Rollback}, nil
+}
+
+// beginImmediate starts a transaction with BEGIN IMMEDIATE on a connection
+// of its own, which database/sql can't do. The BEGIN is retried like any
+// statement since nothing ran yet in the transaction.
+func (d *DB) beginImmediate(ctx context.Context) (*Tx, error) {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	const begin = "BEGIN IMMEDIATE"
+	err = d.retry(ctx, begin, func() error {
+		start := time.Now()
+		_, err := conn.ExecContext(ctx, begin)
+		d.observe(ctx, conn, begin, nil, start, err)
+		return err
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// The transaction must end even when ctx is done, or the connection
+	// would go back to the pool in the middle of it.
+	end := func(stmt string) error {
+		_, err := conn.ExecContext(context.WithoutCancel(ctx), stmt)
+		return err
+	}
+	// rollback ends the transaction or, when it can't, discards the
+	// connection so that no other statement runs in what is left of it.
+	rollback := func() error {
+		if err := end("ROLLBACK"); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+			return err
+		}
+		return nil
+	}
+	return &Tx{
+		q:  conn,
+		db: d,
+		commit: func() error {
+			defer conn.Close()
+			if err := end("COMMIT"); err != nil {
+				if rbErr := rollback(); rbErr != nil {
+					return errors.Join(err, fmt.Errorf("failed to roll back transaction: %w", rbErr))
+				}
+				return err
+			}
+			return nil
+		},
+		rollback: func() error {
+			defer conn.Close()
+			return rollback()
+		},
+	}, nil
+}
+
+type txQueryer interface {
+	queryer
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Tx is a transaction started by DB.BeginTx.
+type Tx struct {
+	q  txQueryer
+	db *DB
+	// commit and rollback are nil when the Tx joined a transaction.
+	commit, rollback func() error
+	done             bool
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := t.q.ExecContext(ctx, query, args...)
+	t.db.observe(ctx, t.q, query, args, start, err)
+	return res, err
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	return t.db.query(ctx, t.q, query, args)
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	return &Row{scan: func(dest []any) error {
+		rows, err := t.db.query(ctx, t.q, query, args)
+		return scanRow(rows, err, dest)
+	}}
+}
+
+func (t *Tx) Commit() error {
+	return t.end(t.commit)
+}
+
+// Rollback aborts the transaction. Like sql.Tx.Rollback it returns
+// sql.ErrTxDone once the transaction ended, so it can be deferred.
+func (t *Tx) Rollback() error {
+	return t.end(t.rollback)
+}
+
+func (t *Tx) end(fn func() error) error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	if fn == nil {
+		return nil
+	}
+	return fn()
+}
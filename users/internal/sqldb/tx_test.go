@@ -0,0 +1,271 @@
+package sqldb_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/admarc/users/internal/sqldb"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFileDB returns a DB on a new database file with an empty users table
+// and the path of the file.
+func newFileDB(t *testing.T, opts sqldb.Options) (*sqldb.DB, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("PRAGMA journal_mode = wal; CREATE TABLE users (id TEXT PRIMARY KEY)")
+	require.NoError(t, err)
+
+	return sqldb.New(db, opts), path
+}
+
+func countUsers(t *testing.T, ctx context.Context, db *sqldb.DB) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM users").Scan(&n))
+	return n
+}
+
+func TestDB_InTx(t *testing.T) {
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name      string
+		fn        func(db *sqldb.DB) func(ctx context.Context) error
+		wantErr   error
+		wantUsers int
+	}{
+		{
+			name: "commits",
+			fn: func(db *sqldb.DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					_, err := db.ExecContext(ctx, "INSERT INTO users VALUES ('a')")
+					return err
+				}
+			},
+			wantUsers: 1,
+		},
+		{
+			name: "rolls back on error",
+			fn: func(db *sqldb.DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if _, err := db.ExecContext(ctx, "INSERT INTO users VALUES ('a')"); err != nil {
+						return err
+					}
+					return failed
+				}
+			},
+			wantErr: failed,
+		},
+		{
+			name: "nested calls and transactions join",
+			fn: func(db *sqldb.DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					err := db.InTx(ctx, func(ctx context.Context) error {
+						_, err := db.ExecContext(ctx, "INSERT INTO users VALUES ('a')")
+						return err
+					})
+					if err != nil {
+						return err
+					}
+
+					tx, err := db.BeginTx(ctx, nil)
+					if err != nil {
+						return err
+					}
+					defer tx.Rollback()
+					if _, err := tx.ExecContext(ctx, "INSERT INTO users VALUES ('b')"); err != nil {
+						return err
+					}
+					return tx.Commit()
+				}
+			},
+			wantUsers: 2,
+		},
+		{
+			name: "joined transactions don't commit",
+			fn: func(db *sqldb.DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					tx, err := db.BeginTx(ctx, nil)
+					if err != nil {
+						return err
+					}
+					if _, err := tx.ExecContext(ctx, "INSERT INTO users VALUES ('a')"); err != nil {
+						return err
+					}
+					if err := tx.Commit(); err != nil {
+						return err
+					}
+					return failed
+				}
+			},
+			wantErr: failed,
+		},
+	}
+	for _, tt := range tests {
+		for _, immediate := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s immediate=%t", tt.name, immediate), func(t *testing.T) {
+				ctx := context.Background()
+				db, _ := newFileDB(t, sqldb.Options{Tx: sqldb.TxOptions{Immediate: immediate}})
+
+				err := db.InTx(ctx, tt.fn(db))
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.wantUsers, countUsers(t, ctx, db))
+			})
+		}
+	}
+}
+
+func TestDB_InTx_panic(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newFileDB(t, sqldb.Options{Tx: sqldb.TxOptions{Immediate: true}})
+
+	assert.PanicsWithValue(t, "boom", func() {
+		db.InTx(ctx, func(ctx context.Context) error {
+			_, err := db.ExecContext(ctx, "INSERT INTO users VALUES ('a')")
+			require.NoError(t, err)
+			panic("boom")
+		})
+	})
+	assert.Equal(t, 0, countUsers(t, ctx, db))
+	assert.NoError(t, db.InTx(ctx, func(ctx context.Context) error { return nil }), "the connection is usable again")
+}
+
+func TestDB_InTx_readOnly(t *testing.T) {
+	ctx := context.Background()
+	_, path := newFileDB(t, sqldb.Options{})
+	rw, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer rw.Close()
+	ro, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	require.NoError(t, err)
+	defer ro.Close()
+	db := sqldb.New(rw, sqldb.Options{ReadOnly: ro})
+
+	err = db.InTx(ctx, func(ctx context.Context) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO users VALUES ('a')")
+		require.NoError(t, err)
+		assert.Equal(t, 1, countUsers(t, ctx, db.ReadOnly()), "the read-only DB reads in the transaction")
+		assert.Equal(t, 0, countUsers(t, context.Background(), db.ReadOnly()))
+		return nil
+	})
+	require.NoError(t, err)
+
+	tx, err := db.BeginTx(ctx, &sqldb.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "INSERT INTO users VALUES ('b')")
+	assert.Error(t, err, "read-only transactions run on the read-only pool")
+}
+
+func TestDB_InTx_immediate(t *testing.T) {
+	tests := []struct {
+		name      string
+		immediate bool
+		wantBusy  bool
+	}{
+		{name: "deferred", immediate: false, wantBusy: false},
+		{name: "immediate", immediate: true, wantBusy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db, path := newFileDB(t, sqldb.Options{Tx: sqldb.TxOptions{Immediate: tt.immediate}})
+			other, err := sql.Open("sqlite3", path+"?_busy_timeout=0")
+			require.NoError(t, err)
+			defer other.Close()
+
+			err = db.InTx(ctx, func(ctx context.Context) error {
+				_, err := other.ExecContext(ctx, "INSERT INTO users VALUES ('a')")
+				return err
+			})
+			var se sqlite3.Error
+			assert.Equal(t, tt.wantBusy, errors.As(err, &se) && se.Code == sqlite3.ErrBusy, "the write lock is taken by BEGIN: %v", err)
+		})
+	}
+}
+
+func TestTx_Commit_immediateFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		stmt      string
+		wantErr   string
+		wantConns int
+	}{
+		{
+			// Deferred foreign keys are checked by COMMIT, which fails and
+			// leaves the transaction open.
+			name:      "rolled back",
+			stmt:      "INSERT INTO teams VALUES ('a', 'missing')",
+			wantErr:   "FOREIGN KEY constraint failed",
+			wantConns: 1,
+		},
+		{
+			name:      "rollback fails",
+			stmt:      "COMMIT",
+			wantErr:   "failed to roll back transaction",
+			wantConns: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sdb, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite3")+"?_foreign_keys=1")
+			require.NoError(t, err)
+			defer sdb.Close()
+			_, err = sdb.Exec(`CREATE TABLE users (id TEXT PRIMARY KEY);
+				CREATE TABLE teams (id TEXT, owner TEXT REFERENCES users (id) DEFERRABLE INITIALLY DEFERRED)`)
+			require.NoError(t, err)
+			db := sqldb.New(sdb, sqldb.Options{Tx: sqldb.TxOptions{Immediate: true}})
+
+			tx, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, tt.stmt)
+			require.NoError(t, err)
+			assert.ErrorContains(t, tx.Commit(), tt.wantErr)
+
+			assert.Equal(t, tt.wantConns, sdb.Stats().OpenConnections, "a connection that may still be in the transaction is discarded")
+			var n int
+			require.NoError(t, db.InTx(ctx, func(ctx context.Context) error {
+				return db.QueryRowContext(ctx, "SELECT count(*) FROM teams").Scan(&n)
+			}))
+			assert.Equal(t, 0, n)
+		})
+	}
+}
+
+func TestTx_done(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newFileDB(t, sqldb.Options{Tx: sqldb.TxOptions{Immediate: true}})
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+	assert.ErrorIs(t, tx.Rollback(), sql.ErrTxDone)
+	assert.ErrorIs(t, tx.Commit(), sql.ErrTxDone)
+}
+
+func TestParseIsolation(t *testing.T) {
+	level, err := sqldb.ParseIsolation("Read_Committed")
+	assert.NoError(t, err)
+	assert.Equal(t, sql.LevelReadCommitted, level)
+
+	level, err = sqldb.ParseIsolation("default")
+	assert.NoError(t, err)
+	assert.Equal(t, sql.LevelDefault, level)
+
+	_, err = sqldb.ParseIsolation("read committed")
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/admarc/users/db/migrations"
+	"github.com/admarc/users/internal/idempotency"
+	"github.com/admarc/users/internal/migrate"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDB opens a fresh database in a temporary directory and applies
+// every migration in db/migrations.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite3"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ms, err := migrate.Load(migrations.FS, ".")
+	require.NoError(t, err)
+	_, err = migrate.New(db, ms, time.Second).Up(context.Background())
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestStorage_Begin(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDB(t)
+	s := Storage{db: db}
+
+	t.Run("success - only one concurrent caller starts", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		started := 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, ok, err := s.Begin(ctx, "concurrent", "hash", time.Now().Add(time.Minute))
+				assert.NoError(t, err)
+				if ok {
+					mu.Lock()
+					started++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, started)
+	})
+
+	t.Run("success - completed record is returned", func(t *testing.T) {
+		_, ok, err := s.Begin(ctx, "completed", "hash", time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		want := idempotency.Record{RequestHash: "hash", Completed: true, Status: 201, ContentType: "application/json", ETag: `"1"`, Location: "/users/1", Body: []byte(`{}`)}
+		require.NoError(t, s.Complete(ctx, "completed", want, time.Now().Add(time.Hour)))
+
+		got, ok, err := s.Begin(ctx, "completed", "other", time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("success - expired reservation can be taken over", func(t *testing.T) {
+		_, ok, err := s.Begin(ctx, "expired", "hash", time.Now().Add(-time.Second))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		_, ok, err = s.Begin(ctx, "expired", "hash", time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("success - released key can be reused", func(t *testing.T) {
+		_, ok, err := s.Begin(ctx, "released", "hash", time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		require.NoError(t, s.Release(ctx, "released"))
+
+		_, ok, err = s.Begin(ctx, "released", "hash", time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestStorage_DeleteExpired(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDB(t)
+	s := Storage{db: db}
+
+	_, _, err := s.Begin(ctx, "old", "hash", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	_, _, err = s.Begin(ctx, "fresh", "hash", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	n, err := s.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,87 @@
+// Package postgres stores idempotency keys in PostgreSQL, with the schema of
+// db/migrations/postgres.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/admarc/users/internal/idempotency"
+)
+
+type Storage struct {
+	db *sql.DB
+}
+
+func NewStorage(db *sql.DB) Storage {
+	return Storage{db: db}
+}
+
+// Begin inserts a reservation for key. An expired record for the same key is
+// replaced, any other existing record is returned untouched. The primary key
+// on idempotency_keys guarantees that only one of several concurrent callers
+// starts.
+func (s Storage) Begin(ctx context.Context, key, requestHash string, lockedUntil time.Time) (idempotency.Record, bool, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO idempotency_keys (key, request_hash, created_at, expires_at) VALUES ($1,$2,$3,$4)
+		ON CONFLICT (key) DO UPDATE SET request_hash = excluded.request_hash, status = NULL, content_type = NULL, etag = NULL, location = NULL, body = NULL,
+			created_at = excluded.created_at, expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at`,
+		key, requestHash, time.Now(), lockedUntil)
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("Failed to reserve idempotency key %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("Failed to reserve idempotency key %w", err)
+	}
+	if n == 1 {
+		return idempotency.Record{RequestHash: requestHash}, true, nil
+	}
+
+	var rec idempotency.Record
+	var status sql.NullInt64
+	var contentType, etag, location sql.NullString
+	err = s.db.QueryRowContext(ctx, "SELECT request_hash, status, content_type, etag, location, body FROM idempotency_keys WHERE key = $1", key).
+		Scan(&rec.RequestHash, &status, &contentType, &etag, &location, &rec.Body)
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("Failed to fetch idempotency key %w", err)
+	}
+	rec.Completed = status.Valid
+	rec.Status = int(status.Int64)
+	rec.ContentType = contentType.String
+	rec.ETag = etag.String
+	rec.Location = location.String
+
+	return rec, false, nil
+}
+
+func (s Storage) Complete(ctx context.Context, key string, rec idempotency.Record, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE idempotency_keys SET status = $1, content_type = $2, etag = $3, location = $4, body = $5, expires_at = $6 WHERE key = $7 AND request_hash = $8",
+		rec.Status, rec.ContentType, rec.ETag, rec.Location, rec.Body, expiresAt, key, rec.RequestHash)
+	if err != nil {
+		return fmt.Errorf("Failed to store idempotent response %w", err)
+	}
+	return nil
+}
+
+func (s Storage) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status IS NULL", key)
+	if err != nil {
+		return fmt.Errorf("Failed to release idempotency key %w", err)
+	}
+	return nil
+}
+
+func (s Storage) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to delete expired idempotency keys %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to delete expired idempotency keys %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,394 @@
+// Package postgres stores users in PostgreSQL, with the schema of
+// db/migrations/postgres.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/admarc/users/internal/logging"
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/sqldb"
+	"github.com/admarc/users/pkg/dbcollector"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// userColumns are the columns read by scanUser, in order.
+const userColumns = "id, name, given_name, family_name, coalesce(email, ''), status, version, created_at, updated_at, deleted_at"
+
+type Storage struct {
+	db *sqldb.DB
+}
+
+func NewStorage(db *sqldb.DB) Storage {
+	return Storage{db: db}
+}
+
+// Create inserts usr with a new id and version 1. The email must not be
+// used by another user.
+func (s Storage) Create(ctx context.Context, usr models.User) (models.User, error) {
+	usr.ID = uuid.NewString()
+	usr.Version = 1
+	usr.CreatedAt = now()
+	usr.UpdatedAt = usr.CreatedAt
+	usr.DeletedAt = nil
+	_, err := s.db.ExecContext(ctx, "INSERT into users (id, name, given_name, family_name, email, status, version, created_at, updated_at) values ($1,$2,$3,$4,$5,$6,$7,$8,$9)",
+		usr.ID, usr.Name, usr.GivenName, usr.FamilyName, nullString(usr.Email), usr.Status, usr.Version, usr.CreatedAt, usr.UpdatedAt)
+	if isUniqueViolation(err) {
+		return models.User{}, fmt.Errorf("Failed to execute insert %w", models.UserEmailTakenErr)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("Failed to execute insert %w", err)
+	}
+	return usr, nil
+}
+
+func (s Storage) Get(ctx context.Context, id string) (models.User, error) {
+	if !validID(id) {
+		return models.User{}, fmt.Errorf("Failed to fetch user %w", models.NotFoundErr)
+	}
+	usr, err := scanUser(s.db.ReadOnly().QueryRowContext(ctx, "select "+userColumns+" from users as u where u.id = $1 and u.deleted_at is null", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, fmt.Errorf("Failed to fetch user %w", models.NotFoundErr)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("Failed to fetch user %w", err)
+	}
+	return usr, nil
+}
+
+// Update stores usr if usr.Version is still the current version and bumps
+// the version.
+func (s Storage) Update(ctx context.Context, usr models.User) (models.User, error) {
+	if !validID(usr.ID) {
+		return models.User{}, fmt.Errorf("Failed to write user %w", models.NotFoundErr)
+	}
+	updated, err := scanUser(s.db.QueryRowContext(ctx, "UPDATE users SET name = $1, given_name = $2, family_name = $3, email = $4, updated_at = $5, version = version + 1 WHERE id = $6 AND version = $7 AND deleted_at IS NULL RETURNING "+userColumns,
+		usr.Name, usr.GivenName, usr.FamilyName, nullString(usr.Email), now(), usr.ID, usr.Version))
+	if isUniqueViolation(err) {
+		return models.User{}, fmt.Errorf("Failed to execute update %w", models.UserEmailTakenErr)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, s.conditionalWriteErr(ctx, usr.ID, false)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("Failed to execute update %w", err)
+	}
+	return updated, nil
+}
+
+// List pages through users like the SQLite storage does: names sort by
+// their bytes and name prefixes ignore case.
+func (s Storage) List(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+	column := "created_at"
+	if params.SortBy == models.UsersSortName {
+		column = "name"
+	}
+	direction, comparison := "ASC", ">"
+	if params.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !params.IncludeDeleted {
+		where = append(where, "deleted_at is null")
+	}
+
+	if params.NamePrefix != "" {
+		where = append(where, fmt.Sprintf(`lower(name) LIKE lower(%s) ESCAPE '\'`, arg(escapeLike(params.NamePrefix)+"%")))
+	}
+
+	if params.Cursor != "" {
+		cursor, err := models.DecodeUsersCursor(params.Cursor, params)
+		if err != nil {
+			return models.UsersPage{}, err
+		}
+		if !validID(cursor.ID) {
+			return models.UsersPage{}, fmt.Errorf("Failed to list users %w",
+				models.InvalidFieldErr(models.InvalidCursorErr, "cursor", "invalid", "cursor is malformed or was issued for another sort"))
+		}
+		if params.SortBy == models.UsersSortName {
+			where = append(where, fmt.Sprintf("(name, id) %s (%s::text, %s::uuid)", comparison, arg(cursor.Name), arg(cursor.ID)))
+		} else {
+			where = append(where, fmt.Sprintf("(created_at, id) %s (%s::timestamptz, %s::uuid)", comparison, arg(cursor.CreatedAt), arg(cursor.ID)))
+		}
+	}
+
+	query := "select " + userColumns + " from users"
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+	}
+	// One extra row tells us whether there is a next page.
+	query += fmt.Sprintf(" order by %s %s, id %s limit %s", column, direction, direction, arg(params.Limit+1))
+
+	// The query has a variant per filter and sort order, measure them as one.
+	rows, err := s.db.ReadOnly().QueryContext(dbcollector.WithQueryName(ctx, "users.list"), query, args...)
+	if err != nil {
+		return models.UsersPage{}, fmt.Errorf("Failed to list users %w", err)
+	}
+	defer rows.Close()
+
+	page := models.UsersPage{Users: make([]models.User, 0, params.Limit)}
+	for rows.Next() {
+		usr, err := scanUser(rows)
+		if err != nil {
+			return models.UsersPage{}, fmt.Errorf("Failed to scan user %w", err)
+		}
+		page.Users = append(page.Users, usr)
+	}
+	if err := rows.Err(); err != nil {
+		return models.UsersPage{}, fmt.Errorf("Failed to list users %w", err)
+	}
+
+	if len(page.Users) > params.Limit {
+		page.Users = page.Users[:params.Limit]
+		page.NextCursor = models.NewUsersCursor(params, page.Users[params.Limit-1]).Encode()
+	}
+
+	return page, nil
+}
+
+// Transition moves the user to t.To provided that it is still at version and
+// in status t.From, and records t.
+func (s Storage) Transition(ctx context.Context, t models.UserTransition, version int64) (models.User, error) {
+	if !validID(t.UserID) {
+		return models.User{}, fmt.Errorf("Failed to write user %w", models.NotFoundErr)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.User{}, fmt.Errorf("Failed to begin transaction %w", err)
+	}
+	defer tx.Rollback()
+
+	at := now()
+	usr, err := scanUser(tx.QueryRowContext(ctx, "UPDATE users SET status = $1, updated_at = $2, version = version + 1 WHERE id = $3 AND version = $4 AND status = $5 AND deleted_at IS NULL RETURNING "+userColumns,
+		t.To, at, t.UserID, version, t.From))
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return models.User{}, s.conditionalWriteErr(ctx, t.UserID, false)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("Failed to execute status change %w", err)
+	}
+
+	if err := insertTransition(ctx, tx, t, at); err != nil {
+		return models.User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.User{}, fmt.Errorf("Failed to commit status change %w", err)
+	}
+	return usr, nil
+}
+
+// Delete soft deletes the user provided that it is still at version and in
+// status t.From, and records t.
+func (s Storage) Delete(ctx context.Context, t models.UserTransition, version int64) error {
+	if !validID(t.UserID) {
+		return fmt.Errorf("Failed to write user %w", models.NotFoundErr)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction %w", err)
+	}
+	defer tx.Rollback()
+
+	at := now()
+	res, err := tx.ExecContext(ctx, "UPDATE users SET status = $1, deleted_at = $2, updated_at = $2, version = version + 1 WHERE id = $3 AND version = $4 AND status = $5 AND deleted_at IS NULL",
+		models.UserStatusDeleted, at, t.UserID, version, t.From)
+	if err != nil {
+		return fmt.Errorf("Failed to execute soft delete %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Failed to execute soft delete %w", err)
+	}
+	if n == 0 {
+		tx.Rollback()
+		return s.conditionalWriteErr(ctx, t.UserID, false)
+	}
+
+	t.To = models.UserStatusDeleted
+	if err := insertTransition(ctx, tx, t, at); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Failed to commit soft delete %w", err)
+	}
+	return nil
+}
+
+// Restore undeletes the user provided that it is still at version, or at any
+// version for models.AnyVersion. The user gets back the status it had before
+// it was deleted, or active when that is not known.
+func (s Storage) Restore(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	if !validID(id) {
+		return models.User{}, fmt.Errorf("Failed to write user %w", models.NotFoundErr)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.User{}, fmt.Errorf("Failed to begin transaction %w", err)
+	}
+	defer tx.Rollback()
+
+	at := now()
+	usr, err := scanUser(tx.QueryRowContext(ctx, `UPDATE users SET deleted_at = NULL, updated_at = $1, version = version + 1,
+		status = coalesce((SELECT t.from_status FROM user_transitions AS t WHERE t.user_id = users.id AND t.to_status = $2 ORDER BY t.id DESC LIMIT 1), $3)
+		WHERE id = $4 AND (version = $5 OR $5 = 0) AND deleted_at IS NOT NULL RETURNING `+userColumns,
+		at, models.UserStatusDeleted, models.UserStatusActive, id, version))
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return models.User{}, s.conditionalWriteErr(ctx, id, true)
+	}
+	if isUniqueViolation(err) {
+		return models.User{}, fmt.Errorf("Failed to restore user %w", models.UserEmailTakenErr)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("Failed to restore user %w", err)
+	}
+
+	t := models.UserTransition{UserID: id, From: models.UserStatusDeleted, To: usr.Status, Actor: change.Actor, Reason: change.Reason}
+	if err := insertTransition(ctx, tx, t, at); err != nil {
+		return models.User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.User{}, fmt.Errorf("Failed to commit restore %w", err)
+	}
+	return usr, nil
+}
+
+// Transitions returns the status changes of the user, oldest first.
+func (s Storage) Transitions(ctx context.Context, id string) ([]models.UserTransition, error) {
+	if !validID(id) {
+		return nil, nil
+	}
+	rows, err := s.db.ReadOnly().QueryContext(ctx, "SELECT user_id, from_status, to_status, actor, reason, created_at FROM user_transitions WHERE user_id = $1 ORDER BY id", id)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list transitions %w", err)
+	}
+	defer rows.Close()
+
+	var ts []models.UserTransition
+	for rows.Next() {
+		var t models.UserTransition
+		if err := rows.Scan(&t.UserID, &t.From, &t.To, &t.Actor, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("Failed to scan transition %w", err)
+		}
+		t.CreatedAt = t.CreatedAt.UTC()
+		ts = append(ts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to list transitions %w", err)
+	}
+	return ts, nil
+}
+
+// Purge hard deletes users soft deleted before deletedBefore together with
+// their transitions.
+func (s Storage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to begin transaction %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_transitions WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1)", deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to execute purge %w", err)
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to execute purge %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to execute purge %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Failed to commit purge %w", err)
+	}
+	logging.FromContext(ctx).DebugContext(ctx, "purged users", "count", n, "deleted_before", deletedBefore.UTC())
+	return n, nil
+}
+
+// conditionalWriteErr explains why a write guarded by id, version and
+// deletion state matched no row: either the user is not there or its
+// version moved on.
+func (s Storage) conditionalWriteErr(ctx context.Context, id string, deleted bool) error {
+	var found int
+	err := s.db.QueryRowContext(ctx, "SELECT 1 FROM users WHERE id = $1 AND (deleted_at IS NOT NULL) = $2", id, deleted).Scan(&found)
+	switch {
+	case err == nil:
+		logging.FromContext(ctx).DebugContext(ctx, "user write lost to a concurrent change", "user_id", id)
+		return fmt.Errorf("Failed to write user %w", models.VersionMismatchErr)
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("Failed to write user %w", models.NotFoundErr)
+	default:
+		return fmt.Errorf("Failed to check user version %w", err)
+	}
+}
+
+func insertTransition(ctx context.Context, tx *sqldb.Tx, t models.UserTransition, at time.Time) error {
+	_, err := tx.ExecContext(ctx, "INSERT into user_transitions (user_id, from_status, to_status, actor, reason, created_at) values ($1,$2,$3,$4,$5,$6)",
+		t.UserID, t.From, t.To, t.Actor, t.Reason, at)
+	if err != nil {
+		return fmt.Errorf("Failed to record transition %w", err)
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads a user with its timestamps in UTC, whatever the time zone
+// of the session.
+func scanUser(row scanner) (models.User, error) {
+	var usr models.User
+	err := row.Scan(&usr.ID, &usr.Name, &usr.GivenName, &usr.FamilyName, &usr.Email, &usr.Status, &usr.Version, &usr.CreatedAt, &usr.UpdatedAt, &usr.DeletedAt)
+	usr.CreatedAt, usr.UpdatedAt = usr.CreatedAt.UTC(), usr.UpdatedAt.UTC()
+	if usr.DeletedAt != nil {
+		deletedAt := usr.DeletedAt.UTC()
+		usr.DeletedAt = &deletedAt
+	}
+	return usr, err
+}
+
+// now returns the current time at the microsecond precision of timestamptz,
+// so that users returned by writes equal the stored ones.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// validID reports whether id is a user id in the canonical form. Other ids
+// match no user, but PostgreSQL would reject them instead of finding
+// nothing.
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil && len(id) == 36
+}
+
+// nullString stores users without an email as NULL so that they don't
+// collide on the unique email index.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/admarc/users/db/migrations"
+	"github.com/admarc/users/internal/migrate"
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/sqldb"
+	"github.com/admarc/users/internal/storage/pgtest"
+	"github.com/admarc/users/internal/users/userstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDB returns a fresh schema of the server at pgtest.DSNEnv with every
+// migration in db/migrations/postgres applied.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := pgtest.NewDB(t)
+	ms, err := migrate.Load(migrations.Postgres, "postgres")
+	require.NoError(t, err)
+	_, err = migrate.NewPostgres(db, ms, time.Second).Up(context.Background())
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestStorage_Contract(t *testing.T) {
+	userstest.Run(t, func(t *testing.T) userstest.Backend {
+		db := sqldb.New(newTestDB(t), sqldb.Options{Postgres: true})
+		return userstest.Backend{Repo: NewStorage(db), Tx: db}
+	})
+}
+
+func TestStorage_timeZone(t *testing.T) {
+	ctx := context.Background()
+
+	// The session time zone is set on the only connection of the pool.
+	db := newTestDB(t)
+	db.SetMaxOpenConns(1)
+	_, err := db.ExecContext(ctx, "SET TIME ZONE 'America/New_York'")
+	require.NoError(t, err)
+	s := NewStorage(sqldb.New(db, sqldb.Options{Postgres: true}))
+
+	usr, err := s.Create(ctx, models.User{Name: "mike", Status: models.UserStatusActive})
+	require.NoError(t, err)
+	got, err := s.Get(ctx, usr.ID)
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, got.CreatedAt.Location())
+	assert.Equal(t, usr.CreatedAt, got.CreatedAt)
+}
@@ -3,37 +3,83 @@ package users
 import (
 	"context"
 	"database/sql"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/admarc/users/db/migrations"
+	"github.com/admarc/users/internal/migrate"
 	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/sqldb"
+	"github.com/admarc/users/internal/users/userstest"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestStorage_Create(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
+// newTestDB opens a fresh database in a temporary directory and applies
+// every migration in db/migrations.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
 
-	defer cancel()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite3"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ms, err := migrate.Load(migrations.FS, ".")
+	require.NoError(t, err)
+	_, err = migrate.New(db, ms, time.Second).Up(context.Background())
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestMigration_UsersProfile(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite3"))
+	require.NoError(t, err)
+	defer db.Close()
 
-	db, err := sql.Open("sqlite3", "/home/adam/Projects/go-playground/users/tmp/db.sqlite3")
+	ms, err := migrate.Load(migrations.FS, ".")
+	require.NoError(t, err)
 
+	// Apply everything up to the rebuild of the users table, add rows in the
+	// old shape and apply the rest.
+	i := 0
+	for i < len(ms) && ms[i].Name != "users_profile" {
+		i++
+	}
+	require.Less(t, i, len(ms))
+	_, err = migrate.New(db, ms[:i], time.Second).Up(ctx)
 	require.NoError(t, err)
 
-	t.Run("success", func(t *testing.T) {
-		s := Storage{
-			db: db,
-		}
+	createdAt := time.Date(2022, 12, 2, 13, 26, 35, 0, time.UTC)
+	_, err = db.ExecContext(ctx, "INSERT into users (id, name, created_at) values ('a', 'mike', ?), ('b', NULL, NULL)", createdAt.Format(timeLayout))
+	require.NoError(t, err)
 
-		usr, err := s.Create(ctx, "mike")
-		require.NoError(t, err)
+	_, err = migrate.New(db, ms, time.Second).Up(ctx)
+	require.NoError(t, err)
 
-		dbUser := models.User{}
-		row := db.QueryRowContext(ctx, "SELECT id, name from users where id = ?", usr.ID)
-		err = row.Scan(&dbUser.ID, &dbUser.Name)
-		require.NoError(t, err)
+	s := Storage{db: sqldb.New(db, sqldb.Options{})}
 
-		assert.Equal(t, usr, dbUser)
-	})
+	usr, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "mike", usr.Name)
+	assert.Equal(t, "", usr.Email)
+	assert.Equal(t, models.UserStatusActive, usr.Status)
+	assert.True(t, createdAt.Equal(usr.CreatedAt))
+	assert.True(t, createdAt.Equal(usr.UpdatedAt))
 
+	usr, err = s.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "", usr.Name)
+	assert.False(t, usr.CreatedAt.IsZero())
+}
+
+func TestStorage_Contract(t *testing.T) {
+	userstest.Run(t, func(t *testing.T) userstest.Backend {
+		db := sqldb.New(newTestDB(t), sqldb.Options{Tx: sqldb.TxOptions{Immediate: true}})
+		return userstest.Backend{Repo: NewStorage(db), Tx: db}
+	})
 }
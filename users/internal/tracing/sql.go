@@ -0,0 +1,237 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WrapDriver returns d with a client span around every statement run in a
+// traced context. system is reported as db.system, like "sqlite".
+//
+// Statements outside of a trace, such as those of background workers, are
+// not traced so that they don't each start a trace of their own.
+func WrapDriver(d driver.Driver, system string) driver.Driver {
+	return tracedDriver{Driver: d, system: system}
+}
+
+type tracedDriver struct {
+	driver.Driver
+	system string
+}
+
+func (d tracedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: c, system: d.system}, nil
+}
+
+type tracedConn struct {
+	driver.Conn
+	system string
+}
+
+var (
+	_ driver.ExecerContext      = &tracedConn{}
+	_ driver.QueryerContext     = &tracedConn{}
+	_ driver.ConnPrepareContext = &tracedConn{}
+	_ driver.ConnBeginTx        = &tracedConn{}
+	_ driver.Pinger             = &tracedConn{}
+	_ driver.SessionResetter    = &tracedConn{}
+	_ driver.NamedValueChecker  = &tracedConn{}
+	_ driver.Validator          = &tracedConn{}
+)
+
+// Unwrap returns the connection of the wrapped driver, for callers that need
+// its driver specific methods through sql.Conn.Raw.
+func (c *tracedConn) Unwrap() driver.Conn {
+	return c.Conn
+}
+
+// start starts a span for query if ctx is traced. The returned span is
+// not recording otherwise.
+func (c *tracedConn) start(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("db.system", c.system)}
+	if query != "" {
+		attrs = append(attrs, attribute.String("db.statement", query))
+	}
+	return tracer().Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.start(ctx, operation(query), query)
+	defer End(span, &err)
+
+	res, err := execer.ExecContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", n))
+	}
+	return res, nil
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.start(ctx, operation(query), query)
+	defer End(span, &err)
+
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (_ driver.Tx, err error) {
+	spanCtx, span := c.start(ctx, "BEGIN", "")
+	defer End(span, &err)
+
+	var tx driver.Tx
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(spanCtx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{Tx: tx, conn: c, ctx: ctx}, nil
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue lets the wrapped driver convert arguments, database/sql
+// falls back to its default conversion on driver.ErrSkip.
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+type tracedTx struct {
+	driver.Tx
+	conn *tracedConn
+	ctx  context.Context
+}
+
+func (t *tracedTx) Commit() (err error) {
+	_, span := t.conn.start(t.ctx, "COMMIT", "")
+	defer End(span, &err)
+	return t.Tx.Commit()
+}
+
+func (t *tracedTx) Rollback() (err error) {
+	_, span := t.conn.start(t.ctx, "ROLLBACK", "")
+	defer End(span, &err)
+	return t.Tx.Rollback()
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	conn  *tracedConn
+	query string
+}
+
+var (
+	_ driver.StmtExecContext  = &tracedStmt{}
+	_ driver.StmtQueryContext = &tracedStmt{}
+)
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (_ driver.Result, err error) {
+	ctx, span := s.conn.start(ctx, operation(s.query), s.query)
+	defer End(span, &err)
+
+	var res driver.Result
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", n))
+	}
+	return res, nil
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
+	ctx, span := s.conn.start(ctx, operation(s.query), s.query)
+	defer End(span, &err)
+
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(values(args))
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+	return vs
+}
+
+// operation returns the leading keyword of query, like SELECT, which names
+// its span.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/admarc/users/internal/tracing/tracingtest"
+	"github.com/go-chi/chi"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	sql.Register("sqlite3-traced-test", WrapDriver(&sqlite3.SQLiteDriver{}, "sqlite"))
+}
+
+func attrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		traceparent string
+		status      int
+		wantName    string
+		wantRoute   string
+		wantTraceID string
+		wantStatus  codes.Code
+	}{
+		{
+			name:        "success continues the trace of the caller",
+			path:        "/users/42",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			status:      http.StatusOK,
+			wantName:    "GET /users/{id}",
+			wantRoute:   "/users/{id}",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantStatus:  codes.Unset,
+		},
+		{
+			name:       "failure marks the span as failed",
+			path:       "/users/42",
+			status:     http.StatusInternalServerError,
+			wantName:   "GET /users/{id}",
+			wantRoute:  "/users/{id}",
+			wantStatus: codes.Error,
+		},
+		{
+			name:       "root of a sub-router",
+			path:       "/users/",
+			status:     http.StatusOK,
+			wantName:   "GET /users",
+			wantRoute:  "/users",
+			wantStatus: codes.Unset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, exp := tracingtest.NewInMemoryProvider()
+			Install(tp)
+
+			var handlerTraceID string
+			r := chi.NewRouter()
+			r.Use(Middleware)
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				handlerTraceID = TraceID(r.Context())
+				w.WriteHeader(tt.status)
+			}
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/", handler)
+				r.Get("/{id}", handler)
+			})
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exp.GetSpans()
+			if !assert.Len(t, spans, 1) {
+				return
+			}
+			span := spans[0]
+			assert.Equal(t, tt.wantName, span.Name)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+			assert.Equal(t, tt.wantStatus, span.Status.Code)
+			assert.Equal(t, tt.wantRoute, attrs(span)["http.route"].AsString())
+			assert.Equal(t, int64(tt.status), attrs(span)["http.response.status_code"].AsInt64())
+			assert.Equal(t, span.SpanContext.TraceID().String(), handlerTraceID)
+			if tt.wantTraceID != "" {
+				assert.Equal(t, tt.wantTraceID, span.SpanContext.TraceID().String())
+				assert.True(t, span.Parent.IsRemote())
+			}
+		})
+	}
+}
+
+func TestWrapDriver(t *testing.T) {
+	tp, exp := tracingtest.NewInMemoryProvider()
+	Install(tp)
+
+	db, err := sql.Open("sqlite3-traced-test", ":memory:")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE users (id TEXT)")
+	assert.NoError(t, err)
+	assert.Empty(t, exp.GetSpans(), "statements outside of a trace are not traced")
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	tx, err := db.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO users (id) VALUES (?), (?)", "a", "b")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	var n int
+	assert.NoError(t, db.QueryRowContext(ctx, "select count(*) from users").Scan(&n))
+	_, err = db.ExecContext(ctx, "INSERT INTO missing (id) VALUES (1)")
+	assert.Error(t, err)
+	parent.End()
+
+	spans := exp.GetSpans()
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"BEGIN", "INSERT", "COMMIT", "SELECT", "INSERT", "parent"}, names)
+	if len(spans) != 6 {
+		return
+	}
+
+	for _, s := range spans[:5] {
+		assert.Equal(t, parent.SpanContext().SpanID(), s.Parent.SpanID())
+		assert.Equal(t, trace.SpanKindClient, s.SpanKind)
+		assert.Equal(t, "sqlite", attrs(s)["db.system"].AsString())
+	}
+	assert.Equal(t, "INSERT INTO users (id) VALUES (?), (?)", attrs(spans[1])["db.statement"].AsString())
+	assert.Equal(t, int64(2), attrs(spans[1])["db.rows_affected"].AsInt64())
+	assert.Equal(t, "select count(*) from users", attrs(spans[3])["db.statement"].AsString())
+	assert.Equal(t, codes.Error, spans[4].Status.Code)
+}
+
+// checkingConn is a connection converting its own arguments and reporting
+// whether it can be reused.
+type checkingConn struct {
+	driver.Conn
+	checked int
+	valid   bool
+}
+
+func (c *checkingConn) CheckNamedValue(*driver.NamedValue) error {
+	c.checked++
+	return nil
+}
+
+func (c *checkingConn) IsValid() bool {
+	return c.valid
+}
+
+func TestTracedConn_forwards(t *testing.T) {
+	inner := &checkingConn{}
+	c := &tracedConn{Conn: inner}
+	assert.NoError(t, c.CheckNamedValue(&driver.NamedValue{Value: 1}))
+	assert.Equal(t, 1, inner.checked)
+	assert.False(t, c.IsValid(), "a bad connection of the wrapped driver is discarded")
+
+	plain := &tracedConn{Conn: struct{ driver.Conn }{}}
+	assert.ErrorIs(t, plain.CheckNamedValue(&driver.NamedValue{Value: 1}), driver.ErrSkip)
+	assert.True(t, plain.IsValid())
+}
@@ -0,0 +1,296 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/google/uuid"
+)
+
+// InMemoryRepository is a Repository for tests of the Service that keeps
+// users in memory. It passes the same contract tests as the storage
+// backends, and the writes made in a transaction of its InMemoryTransactor
+// are undone when the transaction is rolled back.
+type InMemoryRepository struct {
+	tx          *InMemoryTransactor
+	users       map[string]models.User
+	transitions map[string][]models.UserTransition
+}
+
+func NewInMemoryRepository(tx *InMemoryTransactor) *InMemoryRepository {
+	r := &InMemoryRepository{
+		tx:          tx,
+		users:       map[string]models.User{},
+		transitions: map[string][]models.UserTransition{},
+	}
+	tx.track(r)
+	return r
+}
+
+func (r *InMemoryRepository) snapshot() func() {
+	users, transitions := maps.Clone(r.users), maps.Clone(r.transitions)
+	for id, ts := range transitions {
+		transitions[id] = slices.Clip(ts)
+	}
+	return func() {
+		r.users, r.transitions = users, transitions
+	}
+}
+
+// Create inserts usr with a new id and version 1. The email must not be
+// used by another user.
+func (r *InMemoryRepository) Create(ctx context.Context, usr models.User) (models.User, error) {
+	var err error
+	r.tx.atomically(ctx, func() {
+		if r.emailTaken(usr.Email, "") {
+			err = fmt.Errorf("Failed to create user %w", models.UserEmailTakenErr)
+			return
+		}
+		usr.ID = uuid.NewString()
+		usr.Version = 1
+		usr.CreatedAt = time.Now().UTC()
+		usr.UpdatedAt = usr.CreatedAt
+		usr.DeletedAt = nil
+		r.users[usr.ID] = usr
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return usr, nil
+}
+
+func (r *InMemoryRepository) Get(ctx context.Context, id string) (models.User, error) {
+	var (
+		usr   models.User
+		found bool
+	)
+	r.tx.atomically(ctx, func() {
+		usr, found = r.users[id]
+	})
+	if !found || usr.DeletedAt != nil {
+		return models.User{}, fmt.Errorf("Failed to fetch user %w", models.NotFoundErr)
+	}
+	return usr, nil
+}
+
+func (r *InMemoryRepository) List(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+	var cursor models.UsersCursor
+	if params.Cursor != "" {
+		var err error
+		if cursor, err = models.DecodeUsersCursor(params.Cursor, params); err != nil {
+			return models.UsersPage{}, err
+		}
+	}
+
+	// compare orders a before b in the requested sort, ties broken by id.
+	compare := func(a, b models.User) int {
+		c := a.CreatedAt.Compare(b.CreatedAt)
+		if params.SortBy == models.UsersSortName {
+			c = strings.Compare(a.Name, b.Name)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if params.Desc {
+			c = -c
+		}
+		return c
+	}
+	last := models.User{ID: cursor.ID, Name: cursor.Name, CreatedAt: cursor.CreatedAt}
+
+	var users []models.User
+	r.tx.atomically(ctx, func() {
+		for _, usr := range r.users {
+			switch {
+			case usr.DeletedAt != nil && !params.IncludeDeleted:
+			case !strings.HasPrefix(strings.ToLower(usr.Name), strings.ToLower(params.NamePrefix)):
+			case params.Cursor != "" && compare(usr, last) <= 0:
+			default:
+				users = append(users, usr)
+			}
+		}
+	})
+	slices.SortFunc(users, compare)
+
+	page := models.UsersPage{Users: make([]models.User, 0, params.Limit)}
+	page.Users = append(page.Users, users[:min(len(users), params.Limit)]...)
+	if len(users) > params.Limit {
+		page.NextCursor = models.NewUsersCursor(params, page.Users[params.Limit-1]).Encode()
+	}
+	return page, nil
+}
+
+// Update stores usr if usr.Version is still the current version and bumps
+// the version.
+func (r *InMemoryRepository) Update(ctx context.Context, usr models.User) (models.User, error) {
+	var (
+		updated models.User
+		err     error
+	)
+	r.tx.atomically(ctx, func() {
+		if updated, err = r.current(usr.ID, usr.Version, false); err != nil {
+			return
+		}
+		if r.emailTaken(usr.Email, usr.ID) {
+			err = fmt.Errorf("Failed to update user %w", models.UserEmailTakenErr)
+			return
+		}
+		updated.Name, updated.GivenName, updated.FamilyName, updated.Email = usr.Name, usr.GivenName, usr.FamilyName, usr.Email
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Version++
+		r.users[usr.ID] = updated
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// Transition moves the user to t.To provided that it is still at version and
+// in status t.From, and records t.
+func (r *InMemoryRepository) Transition(ctx context.Context, t models.UserTransition, version int64) (models.User, error) {
+	var (
+		usr models.User
+		err error
+	)
+	r.tx.atomically(ctx, func() {
+		if usr, err = r.current(t.UserID, version, false); err != nil {
+			return
+		}
+		if usr.Status != t.From {
+			err = fmt.Errorf("Failed to write user %w", models.VersionMismatchErr)
+			return
+		}
+		usr.Status = t.To
+		usr = r.write(usr, t)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return usr, nil
+}
+
+// Delete soft deletes the user provided that it is still at version and in
+// status t.From, and records t.
+func (r *InMemoryRepository) Delete(ctx context.Context, t models.UserTransition, version int64) error {
+	var err error
+	r.tx.atomically(ctx, func() {
+		var usr models.User
+		if usr, err = r.current(t.UserID, version, false); err != nil {
+			return
+		}
+		if usr.Status != t.From {
+			err = fmt.Errorf("Failed to write user %w", models.VersionMismatchErr)
+			return
+		}
+		t.To = models.UserStatusDeleted
+		usr.Status = t.To
+		usr = r.write(usr, t)
+		deletedAt := usr.UpdatedAt
+		usr.DeletedAt = &deletedAt
+		r.users[usr.ID] = usr
+	})
+	return err
+}
+
+// Restore undeletes the user provided that it is still at version, or at any
+// version for models.AnyVersion. The user gets back the status it had before
+// it was deleted, or active when that is not known.
+func (r *InMemoryRepository) Restore(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	var (
+		usr models.User
+		err error
+	)
+	r.tx.atomically(ctx, func() {
+		if version == models.AnyVersion {
+			version = r.users[id].Version
+		}
+		if usr, err = r.current(id, version, true); err != nil {
+			return
+		}
+		t := models.UserTransition{UserID: id, From: models.UserStatusDeleted, To: models.UserStatusActive, Actor: change.Actor, Reason: change.Reason}
+		ts := r.transitions[id]
+		for i := len(ts) - 1; i >= 0; i-- {
+			if ts[i].To == models.UserStatusDeleted {
+				t.To = ts[i].From
+				break
+			}
+		}
+		usr.Status = t.To
+		usr.DeletedAt = nil
+		usr = r.write(usr, t)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return usr, nil
+}
+
+// Transitions returns the status changes of the user, oldest first.
+func (r *InMemoryRepository) Transitions(ctx context.Context, id string) ([]models.UserTransition, error) {
+	var ts []models.UserTransition
+	r.tx.atomically(ctx, func() {
+		ts = slices.Clone(r.transitions[id])
+	})
+	return ts, nil
+}
+
+// Purge hard deletes users soft deleted before deletedBefore together with
+// their transitions.
+func (r *InMemoryRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+	r.tx.atomically(ctx, func() {
+		for id, usr := range r.users {
+			if usr.DeletedAt != nil && usr.DeletedAt.Before(deletedBefore) {
+				delete(r.users, id)
+				delete(r.transitions, id)
+				n++
+			}
+		}
+	})
+	return n, nil
+}
+
+// current returns the user with the given id at version that is deleted or
+// not as asked.
+func (r *InMemoryRepository) current(id string, version int64, deleted bool) (models.User, error) {
+	usr, found := r.users[id]
+	switch {
+	case !found || (usr.DeletedAt != nil) != deleted:
+		return models.User{}, fmt.Errorf("Failed to write user %w", models.NotFoundErr)
+	case usr.Version != version:
+		return models.User{}, fmt.Errorf("Failed to write user %w", models.VersionMismatchErr)
+	}
+	return usr, nil
+}
+
+// write stores usr under a new version and records t.
+func (r *InMemoryRepository) write(usr models.User, t models.UserTransition) models.User {
+	usr.UpdatedAt = time.Now().UTC()
+	usr.Version++
+	r.users[usr.ID] = usr
+
+	t.CreatedAt = usr.UpdatedAt
+	r.transitions[usr.ID] = append(r.transitions[usr.ID], t)
+	return usr
+}
+
+// emailTaken reports whether a user other than the one with id has the
+// email. Like the unique index of the storage backends it ignores case and
+// counts soft deleted users.
+func (r *InMemoryRepository) emailTaken(email, id string) bool {
+	if email == "" {
+		return false
+	}
+	for _, usr := range r.users {
+		if usr.ID != id && strings.EqualFold(usr.Email, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package users_test
+
+import (
+	"testing"
+
+	"github.com/admarc/users/internal/users"
+	"github.com/admarc/users/internal/users/userstest"
+)
+
+func TestInMemoryRepository_Contract(t *testing.T) {
+	userstest.Run(t, func(t *testing.T) userstest.Backend {
+		tx := users.NewInMemoryTransactor()
+		return userstest.Backend{Repo: users.NewInMemoryRepository(tx), Tx: tx}
+	})
+}
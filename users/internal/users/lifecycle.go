@@ -0,0 +1,39 @@
+package users
+
+import (
+	"fmt"
+
+	"github.com/admarc/users/internal/models"
+)
+
+// transitions lists the statuses a user may move to from each status. Users
+// leave deleted only through Restore, which brings back the status they had
+// before.
+var transitions = map[models.UserStatus][]models.UserStatus{
+	models.UserStatusPending:   {models.UserStatusActive, models.UserStatusDeleted},
+	models.UserStatusActive:    {models.UserStatusSuspended, models.UserStatusLocked, models.UserStatusDeleted},
+	models.UserStatusSuspended: {models.UserStatusActive, models.UserStatusDeleted},
+	models.UserStatusLocked:    {models.UserStatusActive, models.UserStatusSuspended, models.UserStatusDeleted},
+	models.UserStatusDeleted:   {},
+}
+
+// CanTransition reports whether a user in status from may move to status to.
+func CanTransition(from, to models.UserStatus) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTransition(from, to models.UserStatus) error {
+	if CanTransition(from, to) {
+		return nil
+	}
+	return &models.Error{
+		Code:    "illegal_transition",
+		Message: fmt.Sprintf("a %s user can't become %s", from, to),
+		Err:     models.IllegalTransitionErr,
+	}
+}
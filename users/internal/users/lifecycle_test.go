@@ -0,0 +1,36 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from models.UserStatus
+		to   models.UserStatus
+		want bool
+	}{
+		{from: models.UserStatusPending, to: models.UserStatusActive, want: true},
+		{from: models.UserStatusPending, to: models.UserStatusSuspended, want: false},
+		{from: models.UserStatusPending, to: models.UserStatusLocked, want: false},
+		{from: models.UserStatusPending, to: models.UserStatusDeleted, want: true},
+		{from: models.UserStatusActive, to: models.UserStatusActive, want: false},
+		{from: models.UserStatusActive, to: models.UserStatusSuspended, want: true},
+		{from: models.UserStatusActive, to: models.UserStatusLocked, want: true},
+		{from: models.UserStatusActive, to: models.UserStatusPending, want: false},
+		{from: models.UserStatusSuspended, to: models.UserStatusActive, want: true},
+		{from: models.UserStatusSuspended, to: models.UserStatusLocked, want: false},
+		{from: models.UserStatusLocked, to: models.UserStatusActive, want: true},
+		{from: models.UserStatusLocked, to: models.UserStatusSuspended, want: true},
+		{from: models.UserStatusDeleted, to: models.UserStatusActive, want: false},
+		{from: "unknown", to: models.UserStatusActive, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.want, CanTransition(tt.from, tt.to))
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"github.com/admarc/users/internal/models"
 	"sync"
+	"time"
 )
 
 // Ensure, that RepositoryMock does implement Repository.
@@ -19,12 +20,33 @@ var _ Repository = &RepositoryMock{}
 //
 //		// make and configure a mocked Repository
 //		mockedRepository := &RepositoryMock{
-//			CreateFunc: func(ctx context.Context, name string) (models.User, error) {
+//			CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
 //				panic("mock out the Create method")
 //			},
+//			DeleteFunc: func(ctx context.Context, t models.UserTransition, version int64) error {
+//				panic("mock out the Delete method")
+//			},
 //			GetFunc: func(ctx context.Context, id string) (models.User, error) {
 //				panic("mock out the Get method")
 //			},
+//			ListFunc: func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+//				panic("mock out the List method")
+//			},
+//			PurgeFunc: func(ctx context.Context, deletedBefore time.Time) (int64, error) {
+//				panic("mock out the Purge method")
+//			},
+//			RestoreFunc: func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+//				panic("mock out the Restore method")
+//			},
+//			TransitionFunc: func(ctx context.Context, t models.UserTransition, version int64) (models.User, error) {
+//				panic("mock out the Transition method")
+//			},
+//			TransitionsFunc: func(ctx context.Context, id string) ([]models.UserTransition, error) {
+//				panic("mock out the Transitions method")
+//			},
+//			UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+//				panic("mock out the Update method")
+//			},
 //		}
 //
 //		// use mockedRepository in code that requires Repository
@@ -33,19 +55,49 @@ var _ Repository = &RepositoryMock{}
 //	}
 type RepositoryMock struct {
 	// CreateFunc mocks the Create method.
-	CreateFunc func(ctx context.Context, name string) (models.User, error)
+	CreateFunc func(ctx context.Context, usr models.User) (models.User, error)
+
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(ctx context.Context, t models.UserTransition, version int64) error
 
 	// GetFunc mocks the Get method.
 	GetFunc func(ctx context.Context, id string) (models.User, error)
 
+	// ListFunc mocks the List method.
+	ListFunc func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error)
+
+	// PurgeFunc mocks the Purge method.
+	PurgeFunc func(ctx context.Context, deletedBefore time.Time) (int64, error)
+
+	// RestoreFunc mocks the Restore method.
+	RestoreFunc func(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+
+	// TransitionFunc mocks the Transition method.
+	TransitionFunc func(ctx context.Context, t models.UserTransition, version int64) (models.User, error)
+
+	// TransitionsFunc mocks the Transitions method.
+	TransitionsFunc func(ctx context.Context, id string) ([]models.UserTransition, error)
+
+	// UpdateFunc mocks the Update method.
+	UpdateFunc func(ctx context.Context, usr models.User) (models.User, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Create holds details about calls to the Create method.
 		Create []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
-			// Name is the name argument value.
-			Name string
+			// Usr is the usr argument value.
+			Usr models.User
+		}
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T models.UserTransition
+			// Version is the version argument value.
+			Version int64
 		}
 		// Get holds details about calls to the Get method.
 		Get []struct {
@@ -54,27 +106,82 @@ type RepositoryMock struct {
 			// ID is the id argument value.
 			ID string
 		}
+		// List holds details about calls to the List method.
+		List []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Params is the params argument value.
+			Params models.ListUsersParams
+		}
+		// Purge holds details about calls to the Purge method.
+		Purge []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// DeletedBefore is the deletedBefore argument value.
+			DeletedBefore time.Time
+		}
+		// Restore holds details about calls to the Restore method.
+		Restore []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Version is the version argument value.
+			Version int64
+			// Change is the change argument value.
+			Change models.StatusChange
+		}
+		// Transition holds details about calls to the Transition method.
+		Transition []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T models.UserTransition
+			// Version is the version argument value.
+			Version int64
+		}
+		// Transitions holds details about calls to the Transitions method.
+		Transitions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// Update holds details about calls to the Update method.
+		Update []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Usr is the usr argument value.
+			Usr models.User
+		}
 	}
-	lockCreate sync.RWMutex
-	lockGet    sync.RWMutex
+	lockCreate      sync.RWMutex
+	lockDelete      sync.RWMutex
+	lockGet         sync.RWMutex
+	lockList        sync.RWMutex
+	lockPurge       sync.RWMutex
+	lockRestore     sync.RWMutex
+	lockTransition  sync.RWMutex
+	lockTransitions sync.RWMutex
+	lockUpdate      sync.RWMutex
 }
 
 // Create calls CreateFunc.
-func (mock *RepositoryMock) Create(ctx context.Context, name string) (models.User, error) {
+func (mock *RepositoryMock) Create(ctx context.Context, usr models.User) (models.User, error) {
 	if mock.CreateFunc == nil {
 		panic("RepositoryMock.CreateFunc: method is nil but Repository.Create was just called")
 	}
 	callInfo := struct {
-		Ctx  context.Context
-		Name string
+		Ctx context.Context
+		Usr models.User
 	}{
-		Ctx:  ctx,
-		Name: name,
+		Ctx: ctx,
+		Usr: usr,
 	}
 	mock.lockCreate.Lock()
 	mock.calls.Create = append(mock.calls.Create, callInfo)
 	mock.lockCreate.Unlock()
-	return mock.CreateFunc(ctx, name)
+	return mock.CreateFunc(ctx, usr)
 }
 
 // CreateCalls gets all the calls that were made to Create.
@@ -82,12 +189,12 @@ func (mock *RepositoryMock) Create(ctx context.Context, name string) (models.Use
 //
 //	len(mockedRepository.CreateCalls())
 func (mock *RepositoryMock) CreateCalls() []struct {
-	Ctx  context.Context
-	Name string
+	Ctx context.Context
+	Usr models.User
 } {
 	var calls []struct {
-		Ctx  context.Context
-		Name string
+		Ctx context.Context
+		Usr models.User
 	}
 	mock.lockCreate.RLock()
 	calls = mock.calls.Create
@@ -95,6 +202,46 @@ func (mock *RepositoryMock) CreateCalls() []struct {
 	return calls
 }
 
+// Delete calls DeleteFunc.
+func (mock *RepositoryMock) Delete(ctx context.Context, t models.UserTransition, version int64) error {
+	if mock.DeleteFunc == nil {
+		panic("RepositoryMock.DeleteFunc: method is nil but Repository.Delete was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		T       models.UserTransition
+		Version int64
+	}{
+		Ctx:     ctx,
+		T:       t,
+		Version: version,
+	}
+	mock.lockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	mock.lockDelete.Unlock()
+	return mock.DeleteFunc(ctx, t, version)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//
+//	len(mockedRepository.DeleteCalls())
+func (mock *RepositoryMock) DeleteCalls() []struct {
+	Ctx     context.Context
+	T       models.UserTransition
+	Version int64
+} {
+	var calls []struct {
+		Ctx     context.Context
+		T       models.UserTransition
+		Version int64
+	}
+	mock.lockDelete.RLock()
+	calls = mock.calls.Delete
+	mock.lockDelete.RUnlock()
+	return calls
+}
+
 // Get calls GetFunc.
 func (mock *RepositoryMock) Get(ctx context.Context, id string) (models.User, error) {
 	if mock.GetFunc == nil {
@@ -130,3 +277,231 @@ func (mock *RepositoryMock) GetCalls() []struct {
 	mock.lockGet.RUnlock()
 	return calls
 }
+
+// List calls ListFunc.
+func (mock *RepositoryMock) List(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+	if mock.ListFunc == nil {
+		panic("RepositoryMock.ListFunc: method is nil but Repository.List was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Params models.ListUsersParams
+	}{
+		Ctx:    ctx,
+		Params: params,
+	}
+	mock.lockList.Lock()
+	mock.calls.List = append(mock.calls.List, callInfo)
+	mock.lockList.Unlock()
+	return mock.ListFunc(ctx, params)
+}
+
+// ListCalls gets all the calls that were made to List.
+// Check the length with:
+//
+//	len(mockedRepository.ListCalls())
+func (mock *RepositoryMock) ListCalls() []struct {
+	Ctx    context.Context
+	Params models.ListUsersParams
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Params models.ListUsersParams
+	}
+	mock.lockList.RLock()
+	calls = mock.calls.List
+	mock.lockList.RUnlock()
+	return calls
+}
+
+// Purge calls PurgeFunc.
+func (mock *RepositoryMock) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	if mock.PurgeFunc == nil {
+		panic("RepositoryMock.PurgeFunc: method is nil but Repository.Purge was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		DeletedBefore time.Time
+	}{
+		Ctx:           ctx,
+		DeletedBefore: deletedBefore,
+	}
+	mock.lockPurge.Lock()
+	mock.calls.Purge = append(mock.calls.Purge, callInfo)
+	mock.lockPurge.Unlock()
+	return mock.PurgeFunc(ctx, deletedBefore)
+}
+
+// PurgeCalls gets all the calls that were made to Purge.
+// Check the length with:
+//
+//	len(mockedRepository.PurgeCalls())
+func (mock *RepositoryMock) PurgeCalls() []struct {
+	Ctx           context.Context
+	DeletedBefore time.Time
+} {
+	var calls []struct {
+		Ctx           context.Context
+		DeletedBefore time.Time
+	}
+	mock.lockPurge.RLock()
+	calls = mock.calls.Purge
+	mock.lockPurge.RUnlock()
+	return calls
+}
+
+// Restore calls RestoreFunc.
+func (mock *RepositoryMock) Restore(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	if mock.RestoreFunc == nil {
+		panic("RepositoryMock.RestoreFunc: method is nil but Repository.Restore was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}{
+		Ctx:     ctx,
+		ID:      id,
+		Version: version,
+		Change:  change,
+	}
+	mock.lockRestore.Lock()
+	mock.calls.Restore = append(mock.calls.Restore, callInfo)
+	mock.lockRestore.Unlock()
+	return mock.RestoreFunc(ctx, id, version, change)
+}
+
+// RestoreCalls gets all the calls that were made to Restore.
+// Check the length with:
+//
+//	len(mockedRepository.RestoreCalls())
+func (mock *RepositoryMock) RestoreCalls() []struct {
+	Ctx     context.Context
+	ID      string
+	Version int64
+	Change  models.StatusChange
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ID      string
+		Version int64
+		Change  models.StatusChange
+	}
+	mock.lockRestore.RLock()
+	calls = mock.calls.Restore
+	mock.lockRestore.RUnlock()
+	return calls
+}
+
+// Transition calls TransitionFunc.
+func (mock *RepositoryMock) Transition(ctx context.Context, t models.UserTransition, version int64) (models.User, error) {
+	if mock.TransitionFunc == nil {
+		panic("RepositoryMock.TransitionFunc: method is nil but Repository.Transition was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		T       models.UserTransition
+		Version int64
+	}{
+		Ctx:     ctx,
+		T:       t,
+		Version: version,
+	}
+	mock.lockTransition.Lock()
+	mock.calls.Transition = append(mock.calls.Transition, callInfo)
+	mock.lockTransition.Unlock()
+	return mock.TransitionFunc(ctx, t, version)
+}
+
+// TransitionCalls gets all the calls that were made to Transition.
+// Check the length with:
+//
+//	len(mockedRepository.TransitionCalls())
+func (mock *RepositoryMock) TransitionCalls() []struct {
+	Ctx     context.Context
+	T       models.UserTransition
+	Version int64
+} {
+	var calls []struct {
+		Ctx     context.Context
+		T       models.UserTransition
+		Version int64
+	}
+	mock.lockTransition.RLock()
+	calls = mock.calls.Transition
+	mock.lockTransition.RUnlock()
+	return calls
+}
+
+// Transitions calls TransitionsFunc.
+func (mock *RepositoryMock) Transitions(ctx context.Context, id string) ([]models.UserTransition, error) {
+	if mock.TransitionsFunc == nil {
+		panic("RepositoryMock.TransitionsFunc: method is nil but Repository.Transitions was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockTransitions.Lock()
+	mock.calls.Transitions = append(mock.calls.Transitions, callInfo)
+	mock.lockTransitions.Unlock()
+	return mock.TransitionsFunc(ctx, id)
+}
+
+// TransitionsCalls gets all the calls that were made to Transitions.
+// Check the length with:
+//
+//	len(mockedRepository.TransitionsCalls())
+func (mock *RepositoryMock) TransitionsCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockTransitions.RLock()
+	calls = mock.calls.Transitions
+	mock.lockTransitions.RUnlock()
+	return calls
+}
+
+// Update calls UpdateFunc.
+func (mock *RepositoryMock) Update(ctx context.Context, usr models.User) (models.User, error) {
+	if mock.UpdateFunc == nil {
+		panic("RepositoryMock.UpdateFunc: method is nil but Repository.Update was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Usr models.User
+	}{
+		Ctx: ctx,
+		Usr: usr,
+	}
+	mock.lockUpdate.Lock()
+	mock.calls.Update = append(mock.calls.Update, callInfo)
+	mock.lockUpdate.Unlock()
+	return mock.UpdateFunc(ctx, usr)
+}
+
+// UpdateCalls gets all the calls that were made to Update.
+// Check the length with:
+//
+//	len(mockedRepository.UpdateCalls())
+func (mock *RepositoryMock) UpdateCalls() []struct {
+	Ctx context.Context
+	Usr models.User
+} {
+	var calls []struct {
+		Ctx context.Context
+		Usr models.User
+	}
+	mock.lockUpdate.RLock()
+	calls = mock.calls.Update
+	mock.lockUpdate.RUnlock()
+	return calls
+}
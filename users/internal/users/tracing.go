@@ -0,0 +1,98 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/admarc/users/internal/users"
+
+// TracedService is a Service that records a span for every call.
+type TracedService struct {
+	s Service
+}
+
+func NewTracedService(s Service) TracedService {
+	return TracedService{s: s}
+}
+
+// start starts the span of method with the tracer of the global provider at
+// the time of the call.
+func start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, "users.Service."+method, trace.WithAttributes(attrs...))
+}
+
+func (t TracedService) Create(ctx context.Context, usr models.User) (_ models.User, err error) {
+	ctx, span := start(ctx, "Create")
+	defer tracing.End(span, &err)
+
+	usr, err = t.s.Create(ctx, usr)
+	span.SetAttributes(attribute.String("user.id", usr.ID))
+	return usr, err
+}
+
+func (t TracedService) Get(ctx context.Context, id string) (_ models.User, err error) {
+	ctx, span := start(ctx, "Get", attribute.String("user.id", id))
+	defer tracing.End(span, &err)
+	return t.s.Get(ctx, id)
+}
+
+func (t TracedService) List(ctx context.Context, params models.ListUsersParams) (_ models.UsersPage, err error) {
+	ctx, span := start(ctx, "List", attribute.Int("users.limit", params.Limit))
+	defer tracing.End(span, &err)
+	return t.s.List(ctx, params)
+}
+
+func (t TracedService) Update(ctx context.Context, usr models.User) (_ models.User, err error) {
+	ctx, span := start(ctx, "Update", attribute.String("user.id", usr.ID))
+	defer tracing.End(span, &err)
+	return t.s.Update(ctx, usr)
+}
+
+func (t TracedService) Activate(ctx context.Context, id string, version int64, change models.StatusChange) (_ models.User, err error) {
+	ctx, span := start(ctx, "Activate", attribute.String("user.id", id))
+	defer tracing.End(span, &err)
+	return t.s.Activate(ctx, id, version, change)
+}
+
+func (t TracedService) Suspend(ctx context.Context, id string, version int64, change models.StatusChange) (_ models.User, err error) {
+	ctx, span := start(ctx, "Suspend", attribute.String("user.id", id))
+	defer tracing.End(span, &err)
+	return t.s.Suspend(ctx, id, version, change)
+}
+
+func (t TracedService) Lock(ctx context.Context, id string, version int64, change models.StatusChange) (_ models.User, err error) {
+	ctx, span := start(ctx, "Lock", attribute.String("user.id", id))
+	defer tracing.End(span, &err)
+	return t.s.Lock(ctx, id, version, change)
+}
+
+func (t TracedService) Delete(ctx context.Context, id string, version int64, change models.StatusChange) (err error) {
+	ctx, span := start(ctx, "Delete", attribute.String("user.id", id))
+	defer tracing.End(span, &err)
+	return t.s.Delete(ctx, id, version, change)
+}
+
+func (t TracedService) Restore(ctx context.Context, id string, version int64, change models.StatusChange) (_ models.User, err error) {
+	ctx, span := start(ctx, "Restore", attribute.String("user.id", id))
+	defer tracing.End(span, &err)
+	return t.s.Restore(ctx, id, version, change)
+}
+
+func (t TracedService) Transitions(ctx context.Context, id string) (_ []models.UserTransition, err error) {
+	ctx, span := start(ctx, "Transitions", attribute.String("user.id", id))
+	defer tracing.End(span, &err)
+	return t.s.Transitions(ctx, id)
+}
+
+func (t TracedService) Purge(ctx context.Context, retention time.Duration) (_ int64, err error) {
+	ctx, span := start(ctx, "Purge")
+	defer tracing.End(span, &err)
+	return t.s.Purge(ctx, retention)
+}
@@ -0,0 +1,57 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/tracing"
+	"github.com/admarc/users/internal/tracing/tracingtest"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracedService_Get(t *testing.T) {
+	tests := []struct {
+		name       string
+		repoErr    error
+		wantStatus codes.Code
+	}{
+		{
+			name:       "success",
+			wantStatus: codes.Unset,
+		},
+		{
+			name:       "failure is recorded on the span",
+			repoErr:    errors.New("database is locked"),
+			wantStatus: codes.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, exp := tracingtest.NewInMemoryProvider()
+			tracing.Install(tp)
+
+			var repoSpan trace.SpanContext
+			s := NewTracedService(NewService(&RepositoryMock{
+				GetFunc: func(ctx context.Context, id string) (models.User, error) {
+					repoSpan = trace.SpanContextFromContext(ctx)
+					return models.User{ID: id}, tt.repoErr
+				},
+			}, NewInMemoryTransactor()))
+
+			_, err := s.Get(context.Background(), "383673b8-bd9a-41b4-adba-79bc1abc889e")
+			assert.Equal(t, tt.repoErr != nil, err != nil)
+
+			spans := exp.GetSpans()
+			if !assert.Len(t, spans, 1) {
+				return
+			}
+			assert.Equal(t, "users.Service.Get", spans[0].Name)
+			assert.Equal(t, tt.wantStatus, spans[0].Status.Code)
+			assert.Equal(t, spans[0].SpanContext, repoSpan)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package users
+
+import (
+	"context"
+	"sync"
+)
+
+// Transactor runs fn in a transaction. The repository calls made with the
+// context given to fn join it, so that they are applied together or not at
+// all: the transaction is committed when fn returns nil and rolled back when
+// it returns an error or panics. Calls nested in fn join the outer
+// transaction.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type inMemoryTxKey struct{}
+
+// inMemoryState is state of an in-memory fake that an InMemoryTransactor
+// puts back when a transaction is rolled back.
+type inMemoryState interface {
+	// snapshot saves the state and returns a func that restores it.
+	snapshot() (restore func())
+}
+
+// InMemoryTransactor is a Transactor for tests of the Service. Transactions
+// run one at a time and are counted, and the writes they made to the
+// InMemoryRepository values created with the transactor are undone on
+// rollback.
+type InMemoryTransactor struct {
+	mu        sync.Mutex
+	state     []inMemoryState
+	Commits   int
+	Rollbacks int
+}
+
+func NewInMemoryTransactor() *InMemoryTransactor {
+	return &InMemoryTransactor{}
+}
+
+func (t *InMemoryTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(inMemoryTxKey{}) == t {
+		return fn(ctx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	restores := make([]func(), len(t.state))
+	for i, s := range t.state {
+		restores[i] = s.snapshot()
+	}
+	rollback := func() {
+		for _, restore := range restores {
+			restore()
+		}
+		t.Rollbacks++
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, inMemoryTxKey{}, t)); err != nil {
+		rollback()
+		return err
+	}
+	t.Commits++
+	return nil
+}
+
+// track registers s to be restored when a transaction is rolled back.
+func (t *InMemoryTransactor) track(s inMemoryState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state = append(t.state, s)
+}
+
+// atomically runs fn in the transaction in ctx, or on its own when there is
+// none, so that it doesn't interleave with transactions. Unlike InTx it
+// doesn't count and can't roll back, fn must only write once it can't fail.
+func (t *InMemoryTransactor) atomically(ctx context.Context, fn func()) {
+	if ctx.Value(inMemoryTxKey{}) != t {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+	}
+	fn()
+}
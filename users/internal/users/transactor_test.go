@@ -0,0 +1,79 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryTransactor_InTx(t *testing.T) {
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name          string
+		fn            func(tx *InMemoryTransactor) func(ctx context.Context) error
+		wantErr       error
+		wantPanic     bool
+		wantCommits   int
+		wantRollbacks int
+	}{
+		{
+			name: "commits",
+			fn: func(*InMemoryTransactor) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return nil }
+			},
+			wantCommits: 1,
+		},
+		{
+			name: "rolls back on error",
+			fn: func(*InMemoryTransactor) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return failed }
+			},
+			wantErr:       failed,
+			wantRollbacks: 1,
+		},
+		{
+			name: "rolls back on panic",
+			fn: func(*InMemoryTransactor) func(ctx context.Context) error {
+				return func(ctx context.Context) error { panic("boom") }
+			},
+			wantPanic:     true,
+			wantRollbacks: 1,
+		},
+		{
+			name: "nested calls join",
+			fn: func(tx *InMemoryTransactor) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return tx.InTx(ctx, func(ctx context.Context) error { return nil })
+				}
+			},
+			wantCommits: 1,
+		},
+		{
+			name: "nested errors roll back the outer transaction",
+			fn: func(tx *InMemoryTransactor) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return tx.InTx(ctx, func(ctx context.Context) error { return failed })
+				}
+			},
+			wantErr:       failed,
+			wantRollbacks: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := NewInMemoryTransactor()
+
+			run := func() error { return tx.InTx(context.TODO(), tt.fn(tx)) }
+			if tt.wantPanic {
+				assert.Panics(t, func() { run() })
+			} else {
+				assert.ErrorIs(t, run(), tt.wantErr)
+			}
+			assert.Equal(t, tt.wantCommits, tx.Commits)
+			assert.Equal(t, tt.wantRollbacks, tx.Rollbacks)
+		})
+	}
+}
@@ -2,31 +2,68 @@ package users
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/mail"
+	"strings"
+	"time"
 
+	"github.com/admarc/users/internal/logging"
 	"github.com/admarc/users/internal/models"
 )
 
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 1000
+)
+
 //go:generate moq -rm -out repository_mock.go . Repository
 type Repository interface {
-	Create(ctx context.Context, name string) (models.User, error)
+	Create(ctx context.Context, usr models.User) (models.User, error)
 	Get(ctx context.Context, id string) (models.User, error)
+	List(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error)
+	Update(ctx context.Context, usr models.User) (models.User, error)
+	Transition(ctx context.Context, t models.UserTransition, version int64) (models.User, error)
+	Delete(ctx context.Context, t models.UserTransition, version int64) error
+	Restore(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error)
+	Transitions(ctx context.Context, id string) ([]models.UserTransition, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
 
 type Service struct {
 	repo Repository
+	tx   Transactor
 }
 
-func NewService(repo Repository) Service {
-	return Service{repo: repo}
+func NewService(repo Repository, tx Transactor) Service {
+	return Service{repo: repo, tx: tx}
 }
 
-func (s Service) Create(ctx context.Context, name string) (models.User, error) {
-	if name == "" {
-		return models.User{}, fmt.Errorf("invalid name argument: %w", models.UserCreateParamInvalidNameErr)
+// Create stores a new user. The email is normalized and the status defaults
+// to active; a new user can only start out pending or active.
+func (s Service) Create(ctx context.Context, usr models.User) (models.User, error) {
+	if usr.Name == "" {
+		return models.User{}, fmt.Errorf("invalid name argument: %w",
+			models.InvalidFieldErr(models.UserCreateParamInvalidNameErr, "name", "required", "name must not be empty"))
+	}
+
+	email, err := normalizeEmail(usr.Email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("invalid email argument: %w",
+			models.InvalidFieldErr(models.UserCreateParamInvalidEmailErr, "email", "invalid", err.Error()))
+	}
+	usr.Email = email
+
+	switch usr.Status {
+	case "":
+		usr.Status = models.UserStatusActive
+	case models.UserStatusPending, models.UserStatusActive:
+	default:
+		return models.User{}, fmt.Errorf("invalid status argument: %w",
+			models.InvalidFieldErr(models.UserCreateParamInvalidStatusErr, "status", "unsupported", fmt.Sprintf("a user can't be created as %q", usr.Status)))
 	}
 
-	usr, err := s.repo.Create(ctx, name)
+	usr, err = s.repo.Create(ctx, usr)
 	if err != nil {
 		return models.User{}, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -34,6 +71,58 @@ func (s Service) Create(ctx context.Context, name string) (models.User, error) {
 	return usr, nil
 }
 
+// Update replaces the mutable fields of the user identified by usr.ID
+// provided that usr.Version is still the current version. With
+// models.AnyVersion the current version is read and written in one
+// transaction. The email may only be left empty for users that have none,
+// which users created before emails were required don't.
+func (s Service) Update(ctx context.Context, usr models.User) (models.User, error) {
+	if usr.Name == "" {
+		return models.User{}, fmt.Errorf("invalid name argument: %w",
+			models.InvalidFieldErr(models.UserUpdateParamInvalidNameErr, "name", "required", "name must not be empty"))
+	}
+
+	if strings.TrimSpace(usr.Email) == "" {
+		usr.Email = ""
+	} else {
+		email, err := normalizeEmail(usr.Email)
+		if err != nil {
+			return models.User{}, fmt.Errorf("invalid email argument: %w",
+				models.InvalidFieldErr(models.UserUpdateParamInvalidEmailErr, "email", "invalid", err.Error()))
+		}
+		usr.Email = email
+	}
+
+	var err error
+	if usr.Version != models.AnyVersion && usr.Email != "" {
+		usr, err = s.repo.Update(ctx, usr)
+	} else {
+		err = s.tx.InTx(ctx, func(ctx context.Context) error {
+			current, err := s.repo.Get(ctx, usr.ID)
+			if err != nil {
+				return err
+			}
+			switch {
+			case usr.Version == models.AnyVersion:
+				usr.Version = current.Version
+			case usr.Version != current.Version:
+				return models.VersionMismatchErr
+			}
+			if usr.Email == "" && current.Email != "" {
+				return fmt.Errorf("invalid email argument: %w",
+					models.InvalidFieldErr(models.UserUpdateParamInvalidEmailErr, "email", "required", "email must not be empty"))
+			}
+			usr, err = s.repo.Update(ctx, usr)
+			return err
+		})
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return usr, nil
+}
+
 func (s Service) Get(ctx context.Context, id string) (models.User, error) {
 
 	usr, err := s.repo.Get(ctx, id)
@@ -43,3 +132,165 @@ func (s Service) Get(ctx context.Context, id string) (models.User, error) {
 
 	return usr, nil
 }
+
+func (s Service) List(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+	switch {
+	case params.Limit == 0:
+		params.Limit = DefaultListLimit
+	case params.Limit < 0 || params.Limit > MaxListLimit:
+		return models.UsersPage{}, fmt.Errorf("invalid limit argument: %w",
+			models.InvalidFieldErr(models.ListUsersParamInvalidLimitErr, "limit", "out_of_range", fmt.Sprintf("limit must be between 1 and %d", MaxListLimit)))
+	}
+
+	switch params.SortBy {
+	case "":
+		params.SortBy = models.UsersSortCreatedAt
+	case models.UsersSortCreatedAt, models.UsersSortName:
+	default:
+		return models.UsersPage{}, fmt.Errorf("invalid sort argument: %w",
+			models.InvalidFieldErr(models.ListUsersParamInvalidSortErr, "sort", "unsupported", fmt.Sprintf("can't sort by %q", params.SortBy)))
+	}
+
+	page, err := s.repo.List(ctx, params)
+	if err != nil {
+		return models.UsersPage{}, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return page, nil
+}
+
+// Activate moves the user at the given version to active.
+func (s Service) Activate(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	return s.transition(ctx, id, version, models.UserStatusActive, change)
+}
+
+// Suspend moves the user at the given version to suspended.
+func (s Service) Suspend(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	return s.transition(ctx, id, version, models.UserStatusSuspended, change)
+}
+
+// Lock moves the user at the given version to locked.
+func (s Service) Lock(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	return s.transition(ctx, id, version, models.UserStatusLocked, change)
+}
+
+// transition checks and applies the move in one transaction, so that the
+// user checked is the user changed.
+func (s Service) transition(ctx context.Context, id string, version int64, to models.UserStatus, change models.StatusChange) (models.User, error) {
+	var (
+		t   models.UserTransition
+		usr models.User
+	)
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if t, version, err = s.newTransition(ctx, id, version, to, change); err != nil {
+			return err
+		}
+		usr, err = s.repo.Transition(ctx, t, version)
+		return err
+	})
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to change user status: %w", err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "user status changed", "user_id", id, "from", t.From, "to", t.To, "actor", t.Actor)
+
+	return usr, nil
+}
+
+// newTransition checks that the user at the given version, or at any version
+// for models.AnyVersion, may move to status to and describes the move along
+// with the version it applies to.
+func (s Service) newTransition(ctx context.Context, id string, version int64, to models.UserStatus, change models.StatusChange) (models.UserTransition, int64, error) {
+	usr, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return models.UserTransition{}, 0, err
+	}
+	if version != models.AnyVersion && usr.Version != version {
+		return models.UserTransition{}, 0, models.VersionMismatchErr
+	}
+	if err := checkTransition(usr.Status, to); err != nil {
+		return models.UserTransition{}, 0, err
+	}
+
+	return models.UserTransition{UserID: id, From: usr.Status, To: to, Actor: change.Actor, Reason: change.Reason}, usr.Version, nil
+}
+
+// Delete soft deletes the user at the given version. It stays recoverable
+// with Restore until it is purged.
+func (s Service) Delete(ctx context.Context, id string, version int64, change models.StatusChange) error {
+	var t models.UserTransition
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if t, version, err = s.newTransition(ctx, id, version, models.UserStatusDeleted, change); err != nil {
+			return err
+		}
+		return s.repo.Delete(ctx, t, version)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "user deleted", "user_id", id, "actor", t.Actor)
+
+	return nil
+}
+
+// Restore brings back a soft deleted user with the status it had before it
+// was deleted.
+func (s Service) Restore(ctx context.Context, id string, version int64, change models.StatusChange) (models.User, error) {
+	usr, err := s.repo.Restore(ctx, id, version, change)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to restore user: %w", err)
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "user restored", "user_id", id, "status", usr.Status, "actor", change.Actor)
+
+	return usr, nil
+}
+
+// Transitions returns the status changes of the user, oldest first. Like Get
+// it fails with models.NotFoundErr for unknown and soft deleted users.
+func (s Service) Transitions(ctx context.Context, id string) ([]models.UserTransition, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to list user transitions: %w", err)
+	}
+
+	ts, err := s.repo.Transitions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user transitions: %w", err)
+	}
+
+	return ts, nil
+}
+
+// Purge hard deletes users that were soft deleted more than retention ago and
+// returns how many were removed.
+func (s Service) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, fmt.Errorf("retention must be positive: %w", models.PurgeParamInvalidRetentionErr)
+	}
+
+	n, err := s.repo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge users: %w", err)
+	}
+	if n > 0 {
+		logging.FromContext(ctx).InfoContext(ctx, "purged users", "count", n)
+	}
+
+	return n, nil
+}
+
+// normalizeEmail lower cases a bare email address so that addresses differing
+// only in case are treated as the same one.
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", errors.New("email must not be empty")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", fmt.Errorf("%q is not a valid email address", email)
+	}
+
+	return email, nil
+}
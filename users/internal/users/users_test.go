@@ -3,10 +3,13 @@ package users
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/admarc/users/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestService_Create(t *testing.T) {
@@ -14,7 +17,7 @@ func TestService_Create(t *testing.T) {
 		repo Repository
 	}
 	type args struct {
-		name string
+		usr models.User
 	}
 	tests := []struct {
 		name       string
@@ -30,24 +33,60 @@ func TestService_Create(t *testing.T) {
 				repo: &RepositoryMock{},
 			},
 			args: args{
-				name: "",
+				usr: models.User{Name: "", Email: "tod@example.com"},
 			},
 			want:       models.User{},
 			wantErr:    true,
 			wantErrMsg: "invalid name argument",
 		},
+		{
+			name: "failure - empty email",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				usr: models.User{Name: "Tod"},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "email must not be empty",
+		},
+		{
+			name: "failure - malformed email",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				usr: models.User{Name: "Tod", Email: "Tod <tod@example.com>"},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "is not a valid email address",
+		},
+		{
+			name: "failure - unsupported status",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				usr: models.User{Name: "Tod", Email: "tod@example.com", Status: models.UserStatusSuspended},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "invalid status argument",
+		},
 		{
 			name: "failure - repository error",
 			fields: fields{
 				repo: &RepositoryMock{
-					CreateFunc: func(ctx context.Context, name string) (models.User, error) {
-						assert.Equal(t, "Tod", name)
+					CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, "Tod", usr.Name)
 						return models.User{}, errors.New("Failed to execute insert")
 					},
 				},
 			},
 			args: args{
-				name: "Tod",
+				usr: models.User{Name: "Tod", Email: "tod@example.com"},
 			},
 			want:       models.User{},
 			wantErr:    true,
@@ -57,16 +96,33 @@ func TestService_Create(t *testing.T) {
 			name: "success",
 			fields: fields{
 				repo: &RepositoryMock{
-					CreateFunc: func(ctx context.Context, name string) (models.User, error) {
-						assert.Equal(t, "Tod", name)
-						return models.User{ID: "964e531c-7aba-49d1-87c6-7d37b0291d77", Name: "Tod"}, nil
+					CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{Name: "Tod", GivenName: "Tod", Email: "tod@example.com", Status: models.UserStatusActive}, usr)
+						usr.ID = "964e531c-7aba-49d1-87c6-7d37b0291d77"
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				usr: models.User{Name: "Tod", GivenName: "Tod", Email: " Tod@Example.COM "},
+			},
+			want:       models.User{ID: "964e531c-7aba-49d1-87c6-7d37b0291d77", Name: "Tod", GivenName: "Tod", Email: "tod@example.com", Status: models.UserStatusActive},
+			wantErr:    false,
+			wantErrMsg: "",
+		},
+		{
+			name: "success - pending",
+			fields: fields{
+				repo: &RepositoryMock{
+					CreateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						return usr, nil
 					},
 				},
 			},
 			args: args{
-				name: "Tod",
+				usr: models.User{Name: "Tod", Email: "tod@example.com", Status: models.UserStatusPending},
 			},
-			want:       models.User{ID: "964e531c-7aba-49d1-87c6-7d37b0291d77", Name: "Tod"},
+			want:       models.User{Name: "Tod", Email: "tod@example.com", Status: models.UserStatusPending},
 			wantErr:    false,
 			wantErrMsg: "",
 		},
@@ -77,7 +133,7 @@ func TestService_Create(t *testing.T) {
 				repo: tt.fields.repo,
 			}
 			ctx := context.TODO()
-			got, err := s.Create(ctx, tt.args.name)
+			got, err := s.Create(ctx, tt.args.usr)
 
 			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.want, got)
@@ -104,6 +160,7 @@ func TestService_Get(t *testing.T) {
 		want       models.User
 		wantErr    bool
 		wantErrMsg string
+		wantErrIs  error
 	}{
 		{
 			name: "failure - repository error",
@@ -122,6 +179,23 @@ func TestService_Get(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: "Failed to fetch user",
 		},
+		{
+			name: "failure - not found is preserved",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{}, fmt.Errorf("Failed to fetch user %w", models.NotFoundErr)
+					},
+				},
+			},
+			args: args{
+				id: "383673b8-bd9a-41b4-adba-79bc1abc889e",
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "not found",
+			wantErrIs:  models.NotFoundErr,
+		},
 		{
 			name: "success",
 			fields: fields{
@@ -147,6 +221,102 @@ func TestService_Get(t *testing.T) {
 			}
 			got, err := s.Get(tt.args.ctx, tt.args.id)
 
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrIs)
+			}
+			assert.Equal(t, tt.want, got)
+			if err != nil {
+				assert.Containsf(t, err.Error(), tt.wantErrMsg, "expected error containing %q, got %s", tt.wantErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestService_List(t *testing.T) {
+	type fields struct {
+		repo Repository
+	}
+	type args struct {
+		params models.ListUsersParams
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		args       args
+		want       models.UsersPage
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "failure - limit too big",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				params: models.ListUsersParams{Limit: MaxListLimit + 1},
+			},
+			want:       models.UsersPage{},
+			wantErr:    true,
+			wantErrMsg: "invalid limit",
+		},
+		{
+			name: "failure - unknown sort field",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				params: models.ListUsersParams{SortBy: "email"},
+			},
+			want:       models.UsersPage{},
+			wantErr:    true,
+			wantErrMsg: "invalid sort",
+		},
+		{
+			name: "failure - repository error",
+			fields: fields{
+				repo: &RepositoryMock{
+					ListFunc: func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+						return models.UsersPage{}, errors.New("Failed to list users")
+					},
+				},
+			},
+			args: args{
+				params: models.ListUsersParams{},
+			},
+			want:       models.UsersPage{},
+			wantErr:    true,
+			wantErrMsg: "Failed to list users",
+		},
+		{
+			name: "success - defaults applied",
+			fields: fields{
+				repo: &RepositoryMock{
+					ListFunc: func(ctx context.Context, params models.ListUsersParams) (models.UsersPage, error) {
+						assert.Equal(t, models.ListUsersParams{
+							NamePrefix: "to",
+							SortBy:     models.UsersSortCreatedAt,
+							Limit:      DefaultListLimit,
+						}, params)
+						return models.UsersPage{Users: []models.User{{ID: "1", Name: "tod"}}}, nil
+					},
+				},
+			},
+			args: args{
+				params: models.ListUsersParams{NamePrefix: "to"},
+			},
+			want:       models.UsersPage{Users: []models.User{{ID: "1", Name: "tod"}}},
+			wantErr:    false,
+			wantErrMsg: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Service{
+				repo: tt.fields.repo,
+			}
+			got, err := s.List(context.TODO(), tt.args.params)
+
 			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.want, got)
 			if err != nil {
@@ -155,3 +325,460 @@ func TestService_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestService_Update(t *testing.T) {
+	type fields struct {
+		repo Repository
+	}
+	type args struct {
+		usr models.User
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		args       args
+		want       models.User
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "failure - empty name",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e"},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "invalid name argument",
+		},
+		{
+			name: "failure - repository error",
+			fields: fields{
+				repo: &RepositoryMock{
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						return models.User{}, errors.New("Failed to execute update")
+					},
+				},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: "tod@example.com", Version: 1},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "Failed to execute update",
+		},
+		{
+			name: "failure - malformed email",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: "tod"},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "invalid email argument",
+		},
+		{
+			name: "success",
+			fields: fields{
+				repo: &RepositoryMock{
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: "tod@example.com", Version: 1}, usr)
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: "TOD@example.com", Version: 1},
+			},
+			want:       models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: "tod@example.com", Version: 1},
+			wantErr:    false,
+			wantErrMsg: "",
+		},
+		{
+			name: "success - user without email",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{ID: id, Name: "Mike", Version: 1}, nil
+					},
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Version: 1}, usr)
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: " ", Version: 1},
+			},
+			want:       models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Version: 1},
+			wantErr:    false,
+			wantErrMsg: "",
+		},
+		{
+			name: "failure - removing the email",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{ID: id, Name: "Mike", Email: "mike@example.com", Version: 1}, nil
+					},
+				},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Version: 1},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "email must not be empty",
+		},
+		{
+			name: "failure - user without email at a stale version",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{ID: id, Name: "Mike", Version: 2}, nil
+					},
+				},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Version: 1},
+			},
+			want:       models.User{},
+			wantErr:    true,
+			wantErrMsg: "version mismatch",
+		},
+		{
+			name: "success - any version",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{ID: id, Name: "Mike", Email: "mike@example.com", Version: 3}, nil
+					},
+					UpdateFunc: func(ctx context.Context, usr models.User) (models.User, error) {
+						assert.Equal(t, int64(3), usr.Version)
+						usr.Version++
+						return usr, nil
+					},
+				},
+			},
+			args: args{
+				usr: models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: "tod@example.com", Version: models.AnyVersion},
+			},
+			want:       models.User{ID: "383673b8-bd9a-41b4-adba-79bc1abc889e", Name: "Tod", Email: "tod@example.com", Version: 4},
+			wantErr:    false,
+			wantErrMsg: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Service{
+				repo: tt.fields.repo,
+				tx:   NewInMemoryTransactor(),
+			}
+			got, err := s.Update(context.TODO(), tt.args.usr)
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			if err != nil {
+				assert.Containsf(t, err.Error(), tt.wantErrMsg, "expected error containing %q, got %s", tt.wantErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestService_Purge(t *testing.T) {
+	type fields struct {
+		repo Repository
+	}
+	type args struct {
+		retention time.Duration
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		args       args
+		want       int64
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "failure - no retention",
+			fields: fields{
+				repo: &RepositoryMock{},
+			},
+			args: args{
+				retention: 0,
+			},
+			want:       0,
+			wantErr:    true,
+			wantErrMsg: "invalid retention",
+		},
+		{
+			name: "failure - repository error",
+			fields: fields{
+				repo: &RepositoryMock{
+					PurgeFunc: func(ctx context.Context, deletedBefore time.Time) (int64, error) {
+						return 0, errors.New("Failed to execute purge")
+					},
+				},
+			},
+			args: args{
+				retention: time.Hour,
+			},
+			want:       0,
+			wantErr:    true,
+			wantErrMsg: "Failed to execute purge",
+		},
+		{
+			name: "success",
+			fields: fields{
+				repo: &RepositoryMock{
+					PurgeFunc: func(ctx context.Context, deletedBefore time.Time) (int64, error) {
+						assert.WithinDuration(t, time.Now().Add(-time.Hour), deletedBefore, time.Minute)
+						return 3, nil
+					},
+				},
+			},
+			args: args{
+				retention: time.Hour,
+			},
+			want:       3,
+			wantErr:    false,
+			wantErrMsg: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Service{
+				repo: tt.fields.repo,
+			}
+			got, err := s.Purge(context.TODO(), tt.args.retention)
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			if err != nil {
+				assert.Containsf(t, err.Error(), tt.wantErrMsg, "expected error containing %q, got %s", tt.wantErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestService_Suspend(t *testing.T) {
+	getUser := func(status models.UserStatus) func(ctx context.Context, id string) (models.User, error) {
+		return func(ctx context.Context, id string) (models.User, error) {
+			return models.User{ID: id, Name: "Tod", Status: status, Version: 3}, nil
+		}
+	}
+
+	type fields struct {
+		repo Repository
+	}
+	type args struct {
+		version int64
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		args       args
+		want       models.User
+		wantErr    bool
+		wantErrIs  error
+		wantErrMsg string
+	}{
+		{
+			name: "failure - not found",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: func(ctx context.Context, id string) (models.User, error) {
+						return models.User{}, fmt.Errorf("Failed to fetch user %w", models.NotFoundErr)
+					},
+				},
+			},
+			args:      args{version: 3},
+			wantErr:   true,
+			wantErrIs: models.NotFoundErr,
+		},
+		{
+			name: "failure - stale version",
+			fields: fields{
+				repo: &RepositoryMock{GetFunc: getUser(models.UserStatusActive)},
+			},
+			args:      args{version: 2},
+			wantErr:   true,
+			wantErrIs: models.VersionMismatchErr,
+		},
+		{
+			name: "failure - illegal transition",
+			fields: fields{
+				repo: &RepositoryMock{GetFunc: getUser(models.UserStatusPending)},
+			},
+			args:       args{version: 3},
+			wantErr:    true,
+			wantErrIs:  models.IllegalTransitionErr,
+			wantErrMsg: "a pending user can't become suspended",
+		},
+		{
+			name: "success",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: getUser(models.UserStatusActive),
+					TransitionFunc: func(ctx context.Context, tr models.UserTransition, version int64) (models.User, error) {
+						assert.Equal(t, models.UserTransition{
+							UserID: "964e531c-7aba-49d1-87c6-7d37b0291d77",
+							From:   models.UserStatusActive,
+							To:     models.UserStatusSuspended,
+							Actor:  "admin",
+							Reason: "spam",
+						}, tr)
+						assert.Equal(t, int64(3), version)
+						return models.User{ID: tr.UserID, Name: "Tod", Status: tr.To, Version: 4}, nil
+					},
+				},
+			},
+			args: args{version: 3},
+			want: models.User{ID: "964e531c-7aba-49d1-87c6-7d37b0291d77", Name: "Tod", Status: models.UserStatusSuspended, Version: 4},
+		},
+		{
+			name: "success - any version",
+			fields: fields{
+				repo: &RepositoryMock{
+					GetFunc: getUser(models.UserStatusActive),
+					TransitionFunc: func(ctx context.Context, tr models.UserTransition, version int64) (models.User, error) {
+						assert.Equal(t, int64(3), version, "the transition applies to the version read")
+						return models.User{ID: tr.UserID, Name: "Tod", Status: tr.To, Version: 4}, nil
+					},
+				},
+			},
+			args: args{version: models.AnyVersion},
+			want: models.User{ID: "964e531c-7aba-49d1-87c6-7d37b0291d77", Name: "Tod", Status: models.UserStatusSuspended, Version: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := NewInMemoryTransactor()
+			s := Service{
+				repo: tt.fields.repo,
+				tx:   tx,
+			}
+			got, err := s.Suspend(context.TODO(), "964e531c-7aba-49d1-87c6-7d37b0291d77", tt.args.version, models.StatusChange{Actor: "admin", Reason: "spam"})
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Equal(t, 1, tx.Rollbacks)
+			} else {
+				assert.Equal(t, 1, tx.Commits)
+			}
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrIs)
+			}
+			if err != nil {
+				assert.Containsf(t, err.Error(), tt.wantErrMsg, "expected error containing %q, got %s", tt.wantErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestService_Delete(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		s := Service{
+			tx: NewInMemoryTransactor(),
+			repo: &RepositoryMock{
+				GetFunc: func(ctx context.Context, id string) (models.User, error) {
+					return models.User{ID: id, Status: models.UserStatusLocked, Version: 2}, nil
+				},
+				DeleteFunc: func(ctx context.Context, tr models.UserTransition, version int64) error {
+					assert.Equal(t, models.UserTransition{UserID: "1", From: models.UserStatusLocked, To: models.UserStatusDeleted, Actor: "admin"}, tr)
+					assert.Equal(t, int64(2), version)
+					return nil
+				},
+			},
+		}
+		assert.NoError(t, s.Delete(context.TODO(), "1", 2, models.StatusChange{Actor: "admin"}))
+	})
+
+	t.Run("failure - stale version", func(t *testing.T) {
+		s := Service{
+			tx: NewInMemoryTransactor(),
+			repo: &RepositoryMock{
+				GetFunc: func(ctx context.Context, id string) (models.User, error) {
+					return models.User{ID: id, Status: models.UserStatusActive, Version: 3}, nil
+				},
+			},
+		}
+		assert.ErrorIs(t, s.Delete(context.TODO(), "1", 2, models.StatusChange{Actor: "admin"}), models.VersionMismatchErr)
+	})
+}
+
+// TestService_rollback checks that the writes of the Service made in a
+// transaction are undone together when a later one fails.
+func TestService_rollback(t *testing.T) {
+	ctx := context.TODO()
+	tx := NewInMemoryTransactor()
+	s := NewService(NewInMemoryRepository(tx), tx)
+
+	_, err := s.Create(ctx, models.User{Name: "anna", Email: "anna@example.com"})
+	require.NoError(t, err)
+	usr, err := s.Create(ctx, models.User{Name: "mike", Email: "mike@example.com", Status: models.UserStatusPending})
+	require.NoError(t, err)
+
+	err = tx.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.Activate(ctx, usr.ID, usr.Version, models.StatusChange{Actor: "admin"}); err != nil {
+			return err
+		}
+		_, err := s.Update(ctx, models.User{ID: usr.ID, Name: "mike", Email: "anna@example.com", Version: models.AnyVersion})
+		return err
+	})
+	assert.ErrorIs(t, err, models.UserEmailTakenErr)
+	assert.Equal(t, 1, tx.Rollbacks)
+
+	got, err := s.Get(ctx, usr.ID)
+	require.NoError(t, err)
+	assert.Equal(t, usr, got, "the status change is undone")
+	ts, err := s.Transitions(ctx, usr.ID)
+	require.NoError(t, err)
+	assert.Empty(t, ts)
+}
+
+func TestService_Transitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		repo      Repository
+		want      []models.UserTransition
+		wantErrIs error
+	}{
+		{
+			name: "failure - unknown user",
+			repo: &RepositoryMock{
+				GetFunc: func(ctx context.Context, id string) (models.User, error) {
+					return models.User{}, fmt.Errorf("Failed to fetch user %w", models.NotFoundErr)
+				},
+			},
+			wantErrIs: models.NotFoundErr,
+		},
+		{
+			name: "success",
+			repo: &RepositoryMock{
+				GetFunc: func(ctx context.Context, id string) (models.User, error) {
+					return models.User{ID: id, Status: models.UserStatusActive, Version: 2}, nil
+				},
+				TransitionsFunc: func(ctx context.Context, id string) ([]models.UserTransition, error) {
+					return []models.UserTransition{{UserID: id, From: models.UserStatusPending, To: models.UserStatusActive, Actor: "admin"}}, nil
+				},
+			},
+			want: []models.UserTransition{{UserID: "1", From: models.UserStatusPending, To: models.UserStatusActive, Actor: "admin"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Service{repo: tt.repo}
+			got, err := s.Transitions(context.TODO(), "1")
+
+			assert.ErrorIs(t, err, tt.wantErrIs)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,403 @@
+// Package userstest holds the contract tests every users.Repository has to
+// pass, so that the service behaves the same on every database.
+package userstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/admarc/users/internal/users"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Backend is a repository on an empty database and the transactor whose
+// transactions its calls join.
+type Backend struct {
+	Repo users.Repository
+	Tx   users.Transactor
+}
+
+// Run runs the contract tests, each on a Backend of its own returned by
+// newBackend.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, b Backend)
+	}{
+		{name: "Create", test: testCreate},
+		{name: "Update", test: testUpdate},
+		{name: "SoftDelete", test: testSoftDelete},
+		{name: "Transition", test: testTransition},
+		{name: "Purge", test: testPurge},
+		{name: "List", test: testList},
+		{name: "Transactions", test: testTransactions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newBackend(t))
+		})
+	}
+}
+
+func testCreate(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Repo
+
+	t.Run("success", func(t *testing.T) {
+		usr, err := s.Create(ctx, models.User{Name: "mike", GivenName: "Mike", FamilyName: "Smith", Email: "mike@example.com", Status: models.UserStatusPending})
+		require.NoError(t, err)
+		assert.NotEmpty(t, usr.ID)
+		assert.Equal(t, int64(1), usr.Version)
+		assert.True(t, usr.CreatedAt.Equal(usr.UpdatedAt))
+
+		got, err := s.Get(ctx, usr.ID)
+		require.NoError(t, err)
+		assert.Equal(t, usr.ID, got.ID)
+		assert.Equal(t, "mike", got.Name)
+		assert.Equal(t, "Mike", got.GivenName)
+		assert.Equal(t, "Smith", got.FamilyName)
+		assert.Equal(t, "mike@example.com", got.Email)
+		assert.Equal(t, models.UserStatusPending, got.Status)
+		assert.Equal(t, usr.Version, got.Version)
+		assert.True(t, usr.CreatedAt.Equal(got.CreatedAt))
+		assert.True(t, usr.UpdatedAt.Equal(got.UpdatedAt))
+		assert.Nil(t, got.DeletedAt)
+	})
+
+	t.Run("success - users without email", func(t *testing.T) {
+		_, err := s.Create(ctx, models.User{Name: "anna", Status: models.UserStatusActive})
+		require.NoError(t, err)
+		_, err = s.Create(ctx, models.User{Name: "tod", Status: models.UserStatusActive})
+		require.NoError(t, err)
+	})
+
+	t.Run("failure - email taken", func(t *testing.T) {
+		_, err := s.Create(ctx, models.User{Name: "tod", Email: "tod@example.com", Status: models.UserStatusActive})
+		require.NoError(t, err)
+
+		_, err = s.Create(ctx, models.User{Name: "tod", Email: "TOD@example.com", Status: models.UserStatusActive})
+		assert.ErrorIs(t, err, models.UserEmailTakenErr)
+	})
+
+	t.Run("failure - unknown user", func(t *testing.T) {
+		for _, id := range []string{uuid.NewString(), "missing"} {
+			_, err := s.Get(ctx, id)
+			assert.ErrorIs(t, err, models.NotFoundErr, id)
+		}
+	})
+}
+
+func testUpdate(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Repo
+
+	t.Run("success", func(t *testing.T) {
+		usr, err := s.Create(ctx, models.User{Name: "mike", Status: models.UserStatusActive})
+		require.NoError(t, err)
+
+		updated, err := s.Update(ctx, models.User{ID: usr.ID, Name: "tod", Email: "tod@example.com", Version: usr.Version})
+		require.NoError(t, err)
+		assert.Equal(t, "tod", updated.Name)
+		assert.Equal(t, "tod@example.com", updated.Email)
+		assert.Equal(t, usr.Version+1, updated.Version)
+		assert.True(t, usr.CreatedAt.Equal(updated.CreatedAt))
+		assert.False(t, updated.UpdatedAt.Before(usr.UpdatedAt))
+
+		got, err := s.Get(ctx, usr.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updated, got)
+	})
+
+	t.Run("failure - stale version", func(t *testing.T) {
+		usr, err := s.Create(ctx, models.User{Name: "mike", Status: models.UserStatusActive})
+		require.NoError(t, err)
+
+		_, err = s.Update(ctx, models.User{ID: usr.ID, Name: "tod", Version: usr.Version})
+		require.NoError(t, err)
+
+		_, err = s.Update(ctx, models.User{ID: usr.ID, Name: "anna", Version: usr.Version})
+		assert.ErrorIs(t, err, models.VersionMismatchErr)
+	})
+
+	t.Run("failure - email taken", func(t *testing.T) {
+		_, err := s.Create(ctx, models.User{Name: "anna", Email: "anna@example.com", Status: models.UserStatusActive})
+		require.NoError(t, err)
+		usr, err := s.Create(ctx, models.User{Name: "mike", Status: models.UserStatusActive})
+		require.NoError(t, err)
+
+		_, err = s.Update(ctx, models.User{ID: usr.ID, Name: "mike", Email: "Anna@example.com", Version: usr.Version})
+		assert.ErrorIs(t, err, models.UserEmailTakenErr)
+	})
+
+	t.Run("failure - unknown user", func(t *testing.T) {
+		for _, id := range []string{uuid.NewString(), "missing"} {
+			_, err := s.Update(ctx, models.User{ID: id, Name: "tod", Version: 1})
+			assert.ErrorIs(t, err, models.NotFoundErr, id)
+		}
+	})
+}
+
+func testSoftDelete(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Repo
+
+	usr, err := s.Create(ctx, models.User{Name: "mike", Status: models.UserStatusActive})
+	require.NoError(t, err)
+
+	del := models.UserTransition{UserID: usr.ID, From: models.UserStatusActive, Actor: "admin"}
+	assert.ErrorIs(t, s.Delete(ctx, del, usr.Version+1), models.VersionMismatchErr)
+	require.NoError(t, s.Delete(ctx, del, usr.Version))
+	assert.ErrorIs(t, s.Delete(ctx, del, usr.Version+1), models.NotFoundErr)
+
+	_, err = s.Get(ctx, usr.ID)
+	assert.ErrorIs(t, err, models.NotFoundErr)
+
+	page, err := s.List(ctx, models.ListUsersParams{Limit: 10, SortBy: models.UsersSortCreatedAt})
+	require.NoError(t, err)
+	assert.Empty(t, page.Users)
+
+	page, err = s.List(ctx, models.ListUsersParams{Limit: 10, SortBy: models.UsersSortCreatedAt, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, page.Users, 1)
+	require.NotNil(t, page.Users[0].DeletedAt)
+	assert.False(t, page.Users[0].DeletedAt.Before(usr.CreatedAt))
+	assert.Equal(t, models.UserStatusDeleted, page.Users[0].Status)
+
+	_, err = s.Restore(ctx, usr.ID, usr.Version, models.StatusChange{Actor: "admin"})
+	assert.ErrorIs(t, err, models.VersionMismatchErr)
+
+	restored, err := s.Restore(ctx, usr.ID, page.Users[0].Version, models.StatusChange{Actor: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, "mike", restored.Name)
+	assert.Equal(t, models.UserStatusActive, restored.Status)
+	assert.Equal(t, usr.Version+2, restored.Version)
+	assert.Nil(t, restored.DeletedAt)
+
+	_, err = s.Restore(ctx, usr.ID, restored.Version, models.StatusChange{Actor: "admin"})
+	assert.ErrorIs(t, err, models.NotFoundErr)
+	_, err = s.Restore(ctx, "missing", 1, models.StatusChange{Actor: "admin"})
+	assert.ErrorIs(t, err, models.NotFoundErr)
+
+	_, err = s.Get(ctx, usr.ID)
+	assert.NoError(t, err)
+}
+
+func testTransition(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Repo
+
+	usr, err := s.Create(ctx, models.User{Name: "mike", Status: models.UserStatusPending})
+	require.NoError(t, err)
+
+	usr, err = s.Transition(ctx, models.UserTransition{UserID: usr.ID, From: models.UserStatusPending, To: models.UserStatusActive, Actor: "admin"}, usr.Version)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusActive, usr.Status)
+	assert.Equal(t, int64(2), usr.Version)
+
+	_, err = s.Transition(ctx, models.UserTransition{UserID: usr.ID, From: models.UserStatusActive, To: models.UserStatusLocked, Actor: "admin"}, 1)
+	assert.ErrorIs(t, err, models.VersionMismatchErr)
+
+	for _, id := range []string{uuid.NewString(), "missing"} {
+		_, err = s.Transition(ctx, models.UserTransition{UserID: id, From: models.UserStatusActive, To: models.UserStatusLocked, Actor: "admin"}, 1)
+		assert.ErrorIs(t, err, models.NotFoundErr, id)
+	}
+
+	usr, err = s.Transition(ctx, models.UserTransition{UserID: usr.ID, From: models.UserStatusActive, To: models.UserStatusSuspended, Actor: "support", Reason: "spam"}, usr.Version)
+	require.NoError(t, err)
+
+	// A restored user gets back the status it had before it was deleted.
+	require.NoError(t, s.Delete(ctx, models.UserTransition{UserID: usr.ID, From: models.UserStatusSuspended, Actor: "admin"}, usr.Version))
+	restored, err := s.Restore(ctx, usr.ID, models.AnyVersion, models.StatusChange{Actor: "admin", Reason: "mistake"})
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusSuspended, restored.Status)
+
+	ts, err := s.Transitions(ctx, usr.ID)
+	require.NoError(t, err)
+	require.Len(t, ts, 4)
+	got := make([]models.UserTransition, len(ts))
+	for i, tr := range ts {
+		assert.Equal(t, usr.ID, tr.UserID)
+		assert.False(t, tr.CreatedAt.IsZero())
+		got[i] = models.UserTransition{From: tr.From, To: tr.To, Actor: tr.Actor, Reason: tr.Reason}
+	}
+	assert.Equal(t, []models.UserTransition{
+		{From: models.UserStatusPending, To: models.UserStatusActive, Actor: "admin"},
+		{From: models.UserStatusActive, To: models.UserStatusSuspended, Actor: "support", Reason: "spam"},
+		{From: models.UserStatusSuspended, To: models.UserStatusDeleted, Actor: "admin"},
+		{From: models.UserStatusDeleted, To: models.UserStatusSuspended, Actor: "admin", Reason: "mistake"},
+	}, got)
+
+	for _, id := range []string{uuid.NewString(), "missing"} {
+		ts, err := s.Transitions(ctx, id)
+		assert.NoError(t, err)
+		assert.Empty(t, ts, id)
+	}
+}
+
+func testPurge(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Repo
+
+	kept, err := s.Create(ctx, models.User{Name: "kept", Status: models.UserStatusActive})
+	require.NoError(t, err)
+	deleted, err := s.Create(ctx, models.User{Name: "deleted", Status: models.UserStatusActive})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, models.UserTransition{UserID: deleted.ID, From: models.UserStatusActive, Actor: "admin"}, deleted.Version))
+
+	n, err := s.Purge(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n, "the user was deleted too recently")
+
+	n, err = s.Purge(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	page, err := s.List(ctx, models.ListUsersParams{Limit: 10, SortBy: models.UsersSortCreatedAt, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, kept.ID, page.Users[0].ID)
+
+	ts, err := s.Transitions(ctx, deleted.ID)
+	require.NoError(t, err)
+	assert.Empty(t, ts)
+}
+
+func testList(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Repo
+
+	for _, name := range []string{"tod", "mike", "tom", "anna", "to_m"} {
+		_, err := s.Create(ctx, models.User{Name: name, Status: models.UserStatusActive})
+		require.NoError(t, err)
+		// Users created within the precision of the timestamps of a
+		// backend would sort by their random ids.
+		time.Sleep(time.Millisecond)
+	}
+
+	// collect walks every page and returns the names in the order received.
+	collect := func(t *testing.T, params models.ListUsersParams) []string {
+		var names []string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 10, "too many pages")
+			page, err := s.List(ctx, params)
+			require.NoError(t, err)
+			for _, u := range page.Users {
+				names = append(names, u.Name)
+			}
+			if page.NextCursor == "" {
+				return names
+			}
+			params.Cursor = page.NextCursor
+		}
+	}
+
+	t.Run("success - by creation time", func(t *testing.T) {
+		got := collect(t, models.ListUsersParams{SortBy: models.UsersSortCreatedAt, Limit: 2})
+		assert.Equal(t, []string{"tod", "mike", "tom", "anna", "to_m"}, got)
+	})
+
+	t.Run("success - by creation time by default", func(t *testing.T) {
+		got := collect(t, models.ListUsersParams{Limit: 2})
+		assert.Equal(t, []string{"tod", "mike", "tom", "anna", "to_m"}, got)
+	})
+
+	t.Run("success - by creation time descending", func(t *testing.T) {
+		got := collect(t, models.ListUsersParams{SortBy: models.UsersSortCreatedAt, Desc: true, Limit: 3})
+		assert.Equal(t, []string{"to_m", "anna", "tom", "mike", "tod"}, got)
+	})
+
+	t.Run("success - by name descending", func(t *testing.T) {
+		got := collect(t, models.ListUsersParams{SortBy: models.UsersSortName, Desc: true, Limit: 2})
+		assert.Equal(t, []string{"tom", "tod", "to_m", "mike", "anna"}, got)
+	})
+
+	t.Run("success - name prefix with wildcard characters", func(t *testing.T) {
+		got := collect(t, models.ListUsersParams{SortBy: models.UsersSortName, NamePrefix: "to_", Limit: 2})
+		assert.Equal(t, []string{"to_m"}, got)
+	})
+
+	t.Run("success - name prefix ignores case", func(t *testing.T) {
+		got := collect(t, models.ListUsersParams{SortBy: models.UsersSortName, NamePrefix: "TO", Limit: 2})
+		assert.Equal(t, []string{"to_m", "tod", "tom"}, got)
+	})
+
+	t.Run("failure - cursor from another sort", func(t *testing.T) {
+		page, err := s.List(ctx, models.ListUsersParams{SortBy: models.UsersSortName, Limit: 1})
+		require.NoError(t, err)
+
+		_, err = s.List(ctx, models.ListUsersParams{SortBy: models.UsersSortCreatedAt, Limit: 1, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, models.InvalidCursorErr)
+	})
+}
+
+func testTransactions(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Repo
+	failed := errors.New("failed")
+
+	usr, err := s.Create(ctx, models.User{Name: "mike", Email: "mike@example.com", Status: models.UserStatusActive})
+	require.NoError(t, err)
+
+	t.Run("success - committed", func(t *testing.T) {
+		var created models.User
+		err := b.Tx.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			created, err = s.Create(ctx, models.User{Name: "anna", Status: models.UserStatusActive})
+			if err != nil {
+				return err
+			}
+			got, err := s.Get(ctx, created.ID)
+			assert.Equal(t, created.ID, got.ID, "reads in the transaction see its writes")
+			return err
+		})
+		require.NoError(t, err)
+
+		_, err = s.Get(ctx, created.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("success - rolled back", func(t *testing.T) {
+		var created models.User
+		err := b.Tx.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			if created, err = s.Create(ctx, models.User{Name: "tod", Status: models.UserStatusActive}); err != nil {
+				return err
+			}
+			if _, err := s.Transition(ctx, models.UserTransition{UserID: usr.ID, From: models.UserStatusActive, To: models.UserStatusLocked, Actor: "admin"}, usr.Version); err != nil {
+				return err
+			}
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+
+		_, err = s.Get(ctx, created.ID)
+		assert.ErrorIs(t, err, models.NotFoundErr)
+		got, err := s.Get(ctx, usr.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.UserStatusActive, got.Status)
+		assert.Equal(t, usr.Version, got.Version)
+		ts, err := s.Transitions(ctx, usr.ID)
+		require.NoError(t, err)
+		assert.Empty(t, ts)
+	})
+
+	t.Run("failure - errors in the transaction roll it back", func(t *testing.T) {
+		err := b.Tx.InTx(ctx, func(ctx context.Context) error {
+			if _, err := s.Update(ctx, models.User{ID: usr.ID, Name: "renamed", Email: usr.Email, Version: usr.Version}); err != nil {
+				return err
+			}
+			_, err := s.Create(ctx, models.User{Name: "mike", Email: "MIKE@example.com", Status: models.UserStatusActive})
+			return err
+		})
+		assert.ErrorIs(t, err, models.UserEmailTakenErr)
+
+		got, err := s.Get(ctx, usr.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "mike", got.Name)
+	})
+}
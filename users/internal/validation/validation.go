@@ -0,0 +1,283 @@
+// Package validation checks request payloads against rules declared in
+// struct tags, for example:
+//
+//	type CreateUserParams struct {
+//		Name string `json:"name" validate:"required,normalize=nfc,trim,min=1,max=100"`
+//		Role string `json:"role" validate:"enum=admin|member"`
+//		Code string `json:"code" validate:"regex=^[A-Z]{3}$"`
+//	}
+//
+// Supported rules:
+//
+//	required       the value must not be the zero value
+//	min=N, max=N   length bounds in runes for strings, value bounds for numbers
+//	regex=RE       the string must match RE; it must be the last rule since RE may contain commas
+//	enum=a|b|c     the value must be one of the listed options
+//	normalize=F    rewrite the string to Unicode normalization form F (nfc, nfd, nfkc or nfkd)
+//	trim           strip leading and trailing white space
+//
+// Normalization and trimming run before the other rules, so Validate has to
+// be given a pointer. Every violation is reported, not just the first one.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/admarc/users/internal/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+const tagName = "validate"
+
+type rules struct {
+	required  bool
+	min, max  *int64
+	regex     *regexp.Regexp
+	enum      []string
+	normalize *norm.Form
+	trim      bool
+}
+
+type field struct {
+	index  int
+	name   string
+	rules  rules
+	nested bool
+}
+
+var cache sync.Map // map[reflect.Type][]field
+
+// Validate applies the rules declared on the fields of the struct v points
+// to. It returns a *models.Error wrapping models.InvalidErr that lists every
+// violation, or nil.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: expected pointer to struct, got %T", v))
+	}
+
+	var errs []models.FieldError
+	validateStruct(rv.Elem(), "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &models.Error{
+		Code:    "invalid_argument",
+		Message: "request contains invalid fields",
+		Fields:  errs,
+		Err:     models.InvalidErr,
+	}
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *[]models.FieldError) {
+	for _, f := range fieldsOf(rv.Type()) {
+		fv := rv.Field(f.index)
+		name := prefix + f.name
+
+		if f.nested {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					if f.rules.required {
+						*errs = append(*errs, models.FieldError{Field: name, Code: "required", Message: "value is required"})
+					}
+					continue
+				}
+				fv = fv.Elem()
+			}
+			validateStruct(fv, name+".", errs)
+			continue
+		}
+
+		validateField(fv, name, f.rules, errs)
+	}
+}
+
+func validateField(fv reflect.Value, name string, r rules, errs *[]models.FieldError) {
+	add := func(code, format string, args ...any) {
+		*errs = append(*errs, models.FieldError{Field: name, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			if r.required {
+				add("required", "value is required")
+			}
+			return
+		}
+		fv = fv.Elem()
+	}
+
+	if fv.Kind() == reflect.String {
+		s := fv.String()
+		if r.normalize != nil {
+			s = r.normalize.String(s)
+		}
+		if r.trim {
+			s = strings.TrimSpace(s)
+		}
+		if fv.CanSet() {
+			fv.SetString(s)
+		}
+	}
+
+	if fv.IsZero() {
+		if r.required {
+			add("required", "value is required")
+		}
+		return
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		s := fv.String()
+		n := int64(utf8.RuneCountInString(s))
+		if r.min != nil && n < *r.min {
+			add("min_length", "must be at least %d characters long", *r.min)
+		}
+		if r.max != nil && n > *r.max {
+			add("max_length", "must be at most %d characters long", *r.max)
+		}
+		if r.regex != nil && !r.regex.MatchString(s) {
+			add("pattern", "must match %s", r.regex)
+		}
+		if r.enum != nil && !contains(r.enum, s) {
+			add("enum", "must be one of %s", strings.Join(r.enum, ", "))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := fv.Int()
+		if r.min != nil && n < *r.min {
+			add("min", "must be at least %d", *r.min)
+		}
+		if r.max != nil && n > *r.max {
+			add("max", "must be at most %d", *r.max)
+		}
+		if r.enum != nil && !contains(r.enum, strconv.FormatInt(n, 10)) {
+			add("enum", "must be one of %s", strings.Join(r.enum, ", "))
+		}
+	}
+}
+
+func fieldsOf(t reflect.Type) []field {
+	if fs, ok := cache.Load(t); ok {
+		return fs.([]field)
+	}
+
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag, hasTag := sf.Tag.Lookup(tagName)
+		if tag == "-" {
+			continue
+		}
+
+		r, err := parse(tag)
+		if err != nil {
+			panic(fmt.Sprintf("validation: %s.%s: %s", t, sf.Name, err))
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		nested := ft.Kind() == reflect.Struct && ft.NumField() > 0 && !isLeaf(ft)
+		if !hasTag && !nested {
+			continue
+		}
+
+		fs = append(fs, field{index: i, name: jsonName(sf), rules: r, nested: nested})
+	}
+
+	cache.Store(t, fs)
+	return fs
+}
+
+// parse reads the rules of a validate tag.
+func parse(tag string) (rules, error) {
+	var r rules
+	for tag != "" {
+		var opt string
+		if strings.HasPrefix(tag, "regex=") {
+			opt, tag = tag, ""
+		} else {
+			opt, tag, _ = strings.Cut(tag, ",")
+		}
+
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "required":
+			r.required = true
+		case "trim":
+			r.trim = true
+		case "min", "max":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return rules{}, fmt.Errorf("invalid %s %q", key, value)
+			}
+			if key == "min" {
+				r.min = &n
+			} else {
+				r.max = &n
+			}
+		case "regex":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return rules{}, fmt.Errorf("invalid regex %q: %w", value, err)
+			}
+			r.regex = re
+		case "enum":
+			r.enum = strings.Split(value, "|")
+		case "normalize":
+			var f norm.Form
+			switch strings.ToLower(value) {
+			case "nfc":
+				f = norm.NFC
+			case "nfd":
+				f = norm.NFD
+			case "nfkc":
+				f = norm.NFKC
+			case "nfkd":
+				f = norm.NFKD
+			default:
+				return rules{}, fmt.Errorf("unknown normalization form %q", value)
+			}
+			r.normalize = &f
+		default:
+			return rules{}, fmt.Errorf("unknown rule %q", key)
+		}
+	}
+	return r, nil
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// isLeaf reports whether structs of type t are validated as a whole, like
+// time.Time, rather than field by field.
+func isLeaf(t reflect.Type) bool {
+	return t.PkgPath() == "time"
+}
+
+func contains(options []string, s string) bool {
+	for _, o := range options {
+		if o == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/admarc/users/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type params struct {
+	Name    string   `json:"name" validate:"required,normalize=nfc,trim,min=2,max=5"`
+	Role    string   `json:"role" validate:"enum=admin|member"`
+	Age     int      `json:"age" validate:"min=18,max=130"`
+	Code    string   `json:"code" validate:"regex=^[A-Z]{1,3}$"`
+	Note    *string  `json:"note" validate:"max=3"`
+	Address *address `json:"address" validate:"required"`
+	Ignored string
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     params
+		wantParams params
+		wantFields []models.FieldError
+	}{
+		{
+			name:       "success",
+			params:     params{Name: "mike", Role: "admin", Age: 30, Code: "ABC", Note: strPtr("abc"), Address: &address{City: "Oslo"}},
+			wantParams: params{Name: "mike", Role: "admin", Age: 30, Code: "ABC", Note: strPtr("abc"), Address: &address{City: "Oslo"}},
+		},
+		{
+			name:       "success when optional fields are empty",
+			params:     params{Name: "mike", Address: &address{City: "Oslo"}},
+			wantParams: params{Name: "mike", Address: &address{City: "Oslo"}},
+		},
+		{
+			name:       "success with trimmed and normalized name",
+			params:     params{Name: "  Zoé ", Address: &address{City: "Oslo"}},
+			wantParams: params{Name: "Zoé", Address: &address{City: "Oslo"}},
+		},
+		{
+			name:       "failure when required fields are missing",
+			params:     params{Name: "   "},
+			wantParams: params{},
+			wantFields: []models.FieldError{
+				{Field: "name", Code: "required", Message: "value is required"},
+				{Field: "address", Code: "required", Message: "value is required"},
+			},
+		},
+		{
+			name:       "failure when nested field is invalid",
+			params:     params{Name: "mike", Address: &address{}},
+			wantParams: params{Name: "mike", Address: &address{}},
+			wantFields: []models.FieldError{
+				{Field: "address.city", Code: "required", Message: "value is required"},
+			},
+		},
+		{
+			name:       "failure collects every violation",
+			params:     params{Name: "m", Role: "owner", Age: 12, Code: "abc", Note: strPtr("abcd"), Address: &address{City: "Oslo"}},
+			wantParams: params{Name: "m", Role: "owner", Age: 12, Code: "abc", Note: strPtr("abcd"), Address: &address{City: "Oslo"}},
+			wantFields: []models.FieldError{
+				{Field: "name", Code: "min_length", Message: "must be at least 2 characters long"},
+				{Field: "role", Code: "enum", Message: "must be one of admin, member"},
+				{Field: "age", Code: "min", Message: "must be at least 18"},
+				{Field: "code", Code: "pattern", Message: "must match ^[A-Z]{1,3}$"},
+				{Field: "note", Code: "max_length", Message: "must be at most 3 characters long"},
+			},
+		},
+		{
+			name:       "failure when string is too long in runes",
+			params:     params{Name: "ÅÅÅÅÅÅ", Address: &address{City: "Oslo"}},
+			wantParams: params{Name: "ÅÅÅÅÅÅ", Address: &address{City: "Oslo"}},
+			wantFields: []models.FieldError{
+				{Field: "name", Code: "max_length", Message: "must be at most 5 characters long"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.params)
+			assert.Equal(t, tt.wantParams, tt.params)
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, models.InvalidErr)
+			var verr *models.Error
+			if assert.True(t, errors.As(err, &verr)) {
+				assert.Equal(t, tt.wantFields, verr.Fields)
+			}
+		})
+	}
+}
+
+func TestValidate_InvalidTag(t *testing.T) {
+	type badTag struct {
+		Name string `validate:"unknown"`
+	}
+	assert.Panics(t, func() { _ = Validate(&badTag{}) })
+	assert.Panics(t, func() { _ = Validate(params{}) })
+}
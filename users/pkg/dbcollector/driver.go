@@ -0,0 +1,302 @@
+package dbcollector
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = &QueryMetrics{}
+
+// QueryMetrics records the latency and errors of every statement run through
+// a driver wrapped with WrapDriver. Statements are told apart by the name
+// set with WithQueryName or else by their Fingerprint.
+type QueryMetrics struct {
+	Duration *prometheus.HistogramVec
+	Errors   *prometheus.CounterVec
+
+	errorObservers []func(error)
+}
+
+func NewQueryCollector(namespace, subsystem, moduleName string, buckets []float64) *QueryMetrics {
+	label := prometheus.Labels{"db": moduleName}
+	if len(buckets) == 0 {
+		buckets = prometheus.ExponentialBuckets(0.0005, 2, 14)
+	}
+	return &QueryMetrics{
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "query_duration_seconds",
+			Help:        "Time taken by SQL statements, by query.",
+			ConstLabels: label,
+			Buckets:     buckets,
+		}, []string{"query"}),
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "query_errors_total",
+			Help:        "Number of SQL statements that failed, by query.",
+			ConstLabels: label,
+		}, []string{"query"}),
+	}
+}
+
+func (m *QueryMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.Duration.Describe(ch)
+	m.Errors.Describe(ch)
+}
+
+func (m *QueryMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.Duration.Collect(ch)
+	m.Errors.Collect(ch)
+}
+
+// ObserveErrors makes m pass every statement error to fn, to let other
+// collectors classify them. It must be called before the driver is used.
+func (m *QueryMetrics) ObserveErrors(fn func(err error)) {
+	m.errorObservers = append(m.errorObservers, fn)
+}
+
+func (m *QueryMetrics) observe(query string, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	m.Duration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.Errors.WithLabelValues(query).Inc()
+		for _, fn := range m.errorObservers {
+			fn(err)
+		}
+	}
+}
+
+type queryNameKey struct{}
+
+// WithQueryName returns a copy of ctx naming the statements run with it.
+// Naming is useful for statements built at runtime, which would otherwise
+// produce a series per variant.
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+func queryLabel(ctx context.Context, query string) string {
+	if name, ok := ctx.Value(queryNameKey{}).(string); ok && name != "" {
+		return name
+	}
+	return Fingerprint(query)
+}
+
+var (
+	stringLiteral   = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberedParam   = regexp.MustCompile(`\$\d+`)
+	numberLiteral   = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	whitespace      = regexp.MustCompile(`\s+`)
+	placeholderList = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+	tupleList       = regexp.MustCompile(`\(\?\+?\)(?:\s*,\s*\(\?\+?\))+`)
+)
+
+// Fingerprint normalizes query so that statements differing only in
+// literals, placeholders, list lengths, case or spacing come out the same:
+//
+//	SELECT * FROM users WHERE id IN (1, 2,  3) AND name = 'x'
+//
+// becomes
+//
+//	select * from users where id in (?+) and name = ?
+func Fingerprint(query string) string {
+	q := stringLiteral.ReplaceAllString(query, "?")
+	q = numberedParam.ReplaceAllString(q, "?")
+	q = numberLiteral.ReplaceAllString(q, "?")
+	q = whitespace.ReplaceAllString(strings.TrimSpace(q), " ")
+	q = placeholderList.ReplaceAllString(q, "?+")
+	q = tupleList.ReplaceAllString(q, "(?+)+")
+	return strings.ToLower(q)
+}
+
+// WrapDriver returns d recording every statement into m. Open pools on the
+// result with sql.OpenDB and a driver.Connector calling its Open, which lets
+// every pool wrap a driver of its own, or register it with sql.Register
+// under a name other than the one of d.
+func WrapDriver(d driver.Driver, m *QueryMetrics) driver.Driver {
+	return instrumentedDriver{Driver: d, metrics: m}
+}
+
+type instrumentedDriver struct {
+	driver.Driver
+	metrics *QueryMetrics
+}
+
+func (d instrumentedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: c, metrics: d.metrics}, nil
+}
+
+type instrumentedConn struct {
+	driver.Conn
+	metrics *QueryMetrics
+}
+
+var (
+	_ driver.ExecerContext      = &instrumentedConn{}
+	_ driver.QueryerContext     = &instrumentedConn{}
+	_ driver.ConnPrepareContext = &instrumentedConn{}
+	_ driver.ConnBeginTx        = &instrumentedConn{}
+	_ driver.Pinger             = &instrumentedConn{}
+	_ driver.SessionResetter    = &instrumentedConn{}
+	_ driver.NamedValueChecker  = &instrumentedConn{}
+	_ driver.Validator          = &instrumentedConn{}
+)
+
+// Unwrap returns the connection of the wrapped driver.
+func (c *instrumentedConn) Unwrap() driver.Conn {
+	return c.Conn
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	defer func() { c.metrics.observe(queryLabel(ctx, query), start, err) }()
+
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	defer func() { c.metrics.observe(queryLabel(ctx, query), start, err) }()
+
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, metrics: c.metrics, label: queryLabel(ctx, query)}, nil
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (_ driver.Tx, err error) {
+	start := time.Now()
+	defer func() { c.metrics.observe("begin", start, err) }()
+
+	var tx driver.Tx
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: tx, metrics: c.metrics}, nil
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue lets the wrapped driver convert arguments, database/sql
+// falls back to its default conversion on driver.ErrSkip.
+func (c *instrumentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+type instrumentedTx struct {
+	driver.Tx
+	metrics *QueryMetrics
+}
+
+func (t *instrumentedTx) Commit() (err error) {
+	start := time.Now()
+	defer func() { t.metrics.observe("commit", start, err) }()
+	return t.Tx.Commit()
+}
+
+func (t *instrumentedTx) Rollback() (err error) {
+	start := time.Now()
+	defer func() { t.metrics.observe("rollback", start, err) }()
+	return t.Tx.Rollback()
+}
+
+type instrumentedStmt struct {
+	driver.Stmt
+	metrics *QueryMetrics
+	label   string
+}
+
+var (
+	_ driver.StmtExecContext  = &instrumentedStmt{}
+	_ driver.StmtQueryContext = &instrumentedStmt{}
+)
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (_ driver.Result, err error) {
+	start := time.Now()
+	defer func() { s.metrics.observe(s.label, start, err) }()
+
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	return s.Stmt.Exec(values(args))
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
+	start := time.Now()
+	defer func() { s.metrics.observe(s.label, start, err) }()
+
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(values(args))
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+	return vs
+}
@@ -0,0 +1,152 @@
+package dbcollector
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "literals and case",
+			query: "SELECT * FROM users WHERE id = 42 AND name = 'O''Brien'",
+			want:  "select * from users where id = ? and name = ?",
+		},
+		{
+			name:  "spacing",
+			query: "select id\n\tfrom   users\nwhere id = ?  ",
+			want:  "select id from users where id = ?",
+		},
+		{
+			name:  "lists",
+			query: "select id from users where id in (?, ?,?) or id in (1, 2)",
+			want:  "select id from users where id in (?+) or id in (?+)",
+		},
+		{
+			name:  "multi row insert",
+			query: "INSERT INTO users (id, name) VALUES (?, ?), (?, ?), (?, ?)",
+			want:  "insert into users (id, name) values (?+)+",
+		},
+		{
+			name:  "numbered placeholders",
+			query: "select id from users where id = $1 and version = $12",
+			want:  "select id from users where id = ? and version = ?",
+		},
+		{
+			name:  "identifiers with digits are kept",
+			query: "select t1.id from users t1 limit 10",
+			want:  "select t1.id from users t1 limit ?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Fingerprint(tt.query))
+		})
+	}
+}
+
+func TestWrapDriver(t *testing.T) {
+	m := NewQueryCollector("test", "db", "sqlite", nil)
+	var observed []error
+	m.ObserveErrors(func(err error) { observed = append(observed, err) })
+	sql.Register("sqlite3-instrumented-test", WrapDriver(&sqlite3.SQLiteDriver{}, m))
+
+	db, err := sql.Open("sqlite3-instrumented-test", ":memory:")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE users (id TEXT)")
+	assert.NoError(t, err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	for _, id := range []string{"a", "b"} {
+		_, err = tx.ExecContext(ctx, "INSERT INTO users (id) VALUES (?)", id)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tx.Commit())
+
+	var n int
+	assert.NoError(t, db.QueryRowContext(WithQueryName(ctx, "users.count"), "select count(*) from users").Scan(&n))
+	_, err = db.ExecContext(ctx, "INSERT INTO missing (id) VALUES (1)")
+	assert.Error(t, err)
+
+	stmt, err := db.PrepareContext(ctx, "select id from users where id = ?")
+	assert.NoError(t, err)
+	assert.NoError(t, stmt.QueryRowContext(ctx, "a").Scan(new(string)))
+	stmt.Close()
+
+	counts := map[string]uint64{}
+	for _, label := range []string{"create table users (id text)", "begin", "insert into users (id) values (?)", "commit", "users.count", "insert into missing (id) values (?)", "select id from users where id = ?"} {
+		counts[label] = histogramCount(t, m.Duration.WithLabelValues(label))
+	}
+	assert.Equal(t, map[string]uint64{
+		"create table users (id text)":        1,
+		"begin":                               1,
+		"insert into users (id) values (?)":   2,
+		"commit":                              1,
+		"users.count":                         1,
+		"insert into missing (id) values (?)": 1,
+		"select id from users where id = ?":   1,
+	}, counts)
+
+	want := `
+# HELP test_db_query_errors_total Number of SQL statements that failed, by query.
+# TYPE test_db_query_errors_total counter
+test_db_query_errors_total{db="sqlite",query="insert into missing (id) values (?)"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(want), "test_db_query_errors_total"))
+	assert.Len(t, observed, 1)
+}
+
+func histogramCount(t *testing.T, o prometheus.Observer) uint64 {
+	var metric dto.Metric
+	assert.NoError(t, o.(prometheus.Metric).Write(&metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+// checkingConn is a connection converting its own arguments and reporting
+// whether it can be reused.
+type checkingConn struct {
+	driver.Conn
+	checked int
+	valid   bool
+}
+
+func (c *checkingConn) CheckNamedValue(*driver.NamedValue) error {
+	c.checked++
+	return nil
+}
+
+func (c *checkingConn) IsValid() bool {
+	return c.valid
+}
+
+func TestInstrumentedConn_forwards(t *testing.T) {
+	inner := &checkingConn{}
+	c := &instrumentedConn{Conn: inner}
+	assert.NoError(t, c.CheckNamedValue(&driver.NamedValue{Value: 1}))
+	assert.Equal(t, 1, inner.checked)
+	assert.False(t, c.IsValid(), "a bad connection of the wrapped driver is discarded")
+
+	plain := &instrumentedConn{Conn: struct{ driver.Conn }{}}
+	assert.ErrorIs(t, plain.CheckNamedValue(&driver.NamedValue{Value: 1}), driver.ErrSkip)
+	assert.True(t, plain.IsValid())
+}
@@ -0,0 +1,401 @@
+package dbcollector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+var _ prometheus.Collector = &QueryResultsMetrics{}
+
+const (
+	defaultQueryTimeout = 5 * time.Second
+	defaultQueryTTL     = 30 * time.Second
+)
+
+// QueryDefinition describes gauges computed by an SQL query. Every row of
+// the result becomes one sample per Values column, labelled with the Labels
+// columns:
+//
+//	name: users
+//	help: Number of users by status.
+//	query: select status, count(*) as total from users group by status
+//	labels: [status]
+//	values: [total]
+//
+// exports users{status="active"} 42. With several Values columns each one is
+// exported as <name>_<column>.
+//
+// The result is cached for TTL so that frequent scrapes don't hammer the
+// database. When Interval is set the query runs in the background every
+// Interval instead and scrapes only read its last result.
+type QueryDefinition struct {
+	Name     string        `yaml:"name"`
+	Help     string        `yaml:"help"`
+	Query    string        `yaml:"query"`
+	Labels   []string      `yaml:"labels"`
+	Values   []string      `yaml:"values"`
+	Timeout  time.Duration `yaml:"timeout"`
+	TTL      time.Duration `yaml:"ttl"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+func (d QueryDefinition) validate() error {
+	if !metricName.MatchString(d.Name) {
+		return fmt.Errorf("invalid metric name %q", d.Name)
+	}
+	if d.Query == "" {
+		return fmt.Errorf("query %s: query must not be empty", d.Name)
+	}
+	if len(d.Values) == 0 {
+		return fmt.Errorf("query %s: at least one value column is required", d.Name)
+	}
+
+	columns := map[string]bool{}
+	for _, c := range append(append([]string{}, d.Labels...), d.Values...) {
+		if columns[c] {
+			return fmt.Errorf("query %s: column %q is used more than once", d.Name, c)
+		}
+		columns[c] = true
+	}
+	return nil
+}
+
+type queriesFile struct {
+	Queries []QueryDefinition `yaml:"queries"`
+}
+
+// LoadQueries reads query definitions from YAML listing them under queries.
+func LoadQueries(r io.Reader) ([]QueryDefinition, error) {
+	var f queriesFile
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to decode queries: %w", err)
+	}
+
+	names := map[string]bool{}
+	for i, d := range f.Queries {
+		if err := d.validate(); err != nil {
+			return nil, err
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("query %s is defined more than once", d.Name)
+		}
+		names[d.Name] = true
+
+		if d.Timeout <= 0 {
+			f.Queries[i].Timeout = defaultQueryTimeout
+		}
+		if d.TTL <= 0 {
+			f.Queries[i].TTL = defaultQueryTTL
+		}
+	}
+	return f.Queries, nil
+}
+
+// LoadQueriesFile reads query definitions from the YAML file at path.
+func LoadQueriesFile(path string) ([]QueryDefinition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queries file: %w", err)
+	}
+	defer f.Close()
+
+	return LoadQueries(f)
+}
+
+// QueryResultsMetrics exports the results of operator defined queries
+// together with how long they took and how often they failed.
+type QueryResultsMetrics struct {
+	db      *sql.DB
+	queries []*resultQuery
+
+	Errors      *prometheus.CounterVec
+	Duration    *prometheus.GaugeVec
+	LastSuccess *prometheus.GaugeVec
+	valueDescs  map[string][]*prometheus.Desc
+}
+
+// resultQuery is a query with its last result.
+type resultQuery struct {
+	QueryDefinition
+
+	mu      sync.Mutex
+	ranAt   time.Time
+	samples []sample
+	err     error
+}
+
+type sample struct {
+	labels []string
+	values []float64
+}
+
+func NewQueryResultsCollector(namespace, subsystem, moduleName string, db *sql.DB, defs []QueryDefinition) *QueryResultsMetrics {
+	label := prometheus.Labels{"db": moduleName}
+	m := &QueryResultsMetrics{
+		db: db,
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "query_results_errors_total",
+			Help:        "Number of failed runs of metric queries, by query.",
+			ConstLabels: label,
+		}, []string{"query"}),
+		Duration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "query_results_duration_seconds",
+			Help:        "Time taken by the last run of metric queries, by query.",
+			ConstLabels: label,
+		}, []string{"query"}),
+		LastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "query_results_last_success_timestamp_seconds",
+			Help:        "Unix time of the last successful run of metric queries, by query.",
+			ConstLabels: label,
+		}, []string{"query"}),
+		valueDescs: map[string][]*prometheus.Desc{},
+	}
+
+	for _, d := range defs {
+		m.queries = append(m.queries, &resultQuery{QueryDefinition: d})
+
+		for _, v := range d.Values {
+			name := d.Name
+			if len(d.Values) > 1 {
+				name += "_" + v
+			}
+			help := d.Help
+			if help == "" {
+				help = "Result of the " + d.Name + " query."
+			}
+			m.valueDescs[d.Name] = append(m.valueDescs[d.Name], prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, subsystem, name),
+				help,
+				d.Labels,
+				label,
+			))
+		}
+	}
+	return m
+}
+
+// Run refreshes the queries that have an Interval until ctx is done.
+func (m *QueryResultsMetrics) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, q := range m.queries {
+		if q.Interval <= 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(q *resultQuery) {
+			defer wg.Done()
+
+			m.refresh(ctx, q)
+			t := time.NewTicker(q.Interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					m.refresh(ctx, q)
+				}
+			}
+		}(q)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (m *QueryResultsMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, q := range m.queries {
+		for _, d := range m.valueDescs[q.Name] {
+			ch <- d
+		}
+	}
+	m.Errors.Describe(ch)
+	m.Duration.Describe(ch)
+	m.LastSuccess.Describe(ch)
+}
+
+// Collect runs the queries whose cached result is older than their TTL,
+// concurrently, and exports the results.
+func (m *QueryResultsMetrics) Collect(ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+	for _, q := range m.queries {
+		wg.Add(1)
+		go func(q *resultQuery) {
+			defer wg.Done()
+
+			samples, err := m.result(q)
+			if err != nil {
+				return
+			}
+			for _, s := range samples {
+				for i, d := range m.valueDescs[q.Name] {
+					ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, s.values[i], s.labels...)
+				}
+			}
+		}(q)
+	}
+	wg.Wait()
+
+	m.Errors.Collect(ch)
+	m.Duration.Collect(ch)
+	m.LastSuccess.Collect(ch)
+}
+
+// result returns the last result of q, running it first unless it runs in
+// the background or its last run is younger than its TTL. Concurrent scrapes
+// wait for a single run.
+func (m *QueryResultsMetrics) result(q *resultQuery) ([]sample, error) {
+	if q.Interval <= 0 {
+		q.mu.Lock()
+		stale := time.Since(q.ranAt) >= q.TTL
+		q.mu.Unlock()
+		if stale {
+			m.refresh(context.Background(), q)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.samples, q.err
+}
+
+func (m *QueryResultsMetrics) refresh(ctx context.Context, q *resultQuery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.Interval <= 0 && time.Since(q.ranAt) < q.TTL {
+		// Another scrape refreshed it while this one waited for the lock.
+		return
+	}
+
+	start := time.Now()
+	samples, err := m.run(ctx, q.QueryDefinition)
+	m.Duration.WithLabelValues(q.Name).Set(time.Since(start).Seconds())
+
+	q.ranAt = time.Now()
+	q.samples, q.err = samples, err
+	if err != nil {
+		m.Errors.WithLabelValues(q.Name).Inc()
+		return
+	}
+	m.LastSuccess.WithLabelValues(q.Name).Set(float64(q.ranAt.Unix()))
+}
+
+func (m *QueryResultsMetrics) run(ctx context.Context, d QueryDefinition) ([]sample, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(WithQueryName(ctx, "results."+d.Name), d.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query %s: %w", d.Name, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of query %s: %w", d.Name, err)
+	}
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		index[c] = i
+	}
+	for _, c := range append(append([]string{}, d.Labels...), d.Values...) {
+		if _, ok := index[c]; !ok {
+			return nil, fmt.Errorf("query %s: result has no column %q", d.Name, c)
+		}
+	}
+
+	var samples []sample
+	seen := map[string]bool{}
+	for rows.Next() {
+		raw := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range raw {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan query %s: %w", d.Name, err)
+		}
+
+		s := sample{labels: make([]string, len(d.Labels)), values: make([]float64, len(d.Values))}
+		for i, c := range d.Labels {
+			s.labels[i] = labelValue(raw[index[c]])
+		}
+		// NULL and '' both become "", exporting both rows would fail the
+		// whole scrape.
+		key := strings.Join(s.labels, "\xff")
+		if seen[key] {
+			return nil, fmt.Errorf("query %s: more than one row with labels %q", d.Name, s.labels)
+		}
+		seen[key] = true
+		for i, c := range d.Values {
+			v, err := numericValue(raw[index[c]])
+			if err != nil {
+				return nil, fmt.Errorf("query %s: column %q: %w", d.Name, c, err)
+			}
+			s.values[i] = v
+		}
+		samples = append(samples, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to run query %s: %w", d.Name, err)
+	}
+	return samples, nil
+}
+
+func labelValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func numericValue(v any) (float64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case time.Time:
+		return float64(v.Unix()), nil
+	default:
+		return 0, fmt.Errorf("unsupported value of type %T", v)
+	}
+}
@@ -0,0 +1,205 @@
+package dbcollector
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestLoadQueries(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []QueryDefinition
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			yaml: `
+queries:
+  - name: users
+    query: select status, count(*) as total from users group by status
+    labels: [status]
+    values: [total]
+  - name: signups
+    query: select count(*) as total from users
+    values: [total]
+    timeout: 1s
+    interval: 1m
+`,
+			want: []QueryDefinition{
+				{
+					Name:    "users",
+					Query:   "select status, count(*) as total from users group by status",
+					Labels:  []string{"status"},
+					Values:  []string{"total"},
+					Timeout: defaultQueryTimeout,
+					TTL:     defaultQueryTTL,
+				},
+				{
+					Name:     "signups",
+					Query:    "select count(*) as total from users",
+					Values:   []string{"total"},
+					Timeout:  time.Second,
+					TTL:      defaultQueryTTL,
+					Interval: time.Minute,
+				},
+			},
+		},
+		{
+			name:    "unknown field",
+			yaml:    "queries:\n  - name: users\n    sql: select 1\n",
+			wantErr: "field sql not found",
+		},
+		{
+			name:    "invalid name",
+			yaml:    "queries:\n  - name: users-total\n    query: select 1 as v\n    values: [v]\n",
+			wantErr: `invalid metric name "users-total"`,
+		},
+		{
+			name:    "no values",
+			yaml:    "queries:\n  - name: users\n    query: select 1 as v\n",
+			wantErr: "at least one value column is required",
+		},
+		{
+			name:    "column used twice",
+			yaml:    "queries:\n  - name: users\n    query: select 1 as v\n    labels: [v]\n    values: [v]\n",
+			wantErr: `column "v" is used more than once`,
+		},
+		{
+			name:    "duplicate name",
+			yaml:    "queries:\n  - name: users\n    query: select 1 as v\n    values: [v]\n  - name: users\n    query: select 2 as v\n    values: [v]\n",
+			wantErr: "query users is defined more than once",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadQueries(strings.NewReader(tt.yaml))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQueryResultsMetrics(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+CREATE TABLE users (id TEXT, status TEXT, score REAL);
+INSERT INTO users VALUES ('a', 'active', 1.5), ('b', 'active', 2), ('c', 'locked', NULL);
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	m := NewQueryResultsCollector("test", "db", "sqlite", db, []QueryDefinition{
+		{
+			Name:    "users",
+			Help:    "Users by status.",
+			Query:   "select status, count(*) as total, sum(score) as score from users group by status",
+			Labels:  []string{"status"},
+			Values:  []string{"total", "score"},
+			Timeout: time.Second,
+			TTL:     time.Hour,
+		},
+		{
+			Name:    "broken",
+			Query:   "select count(*) as total from missing",
+			Values:  []string{"total"},
+			Timeout: time.Second,
+			TTL:     time.Hour,
+		},
+		{
+			// NULL and '' are both exported as status="".
+			Name:    "duplicate_labels",
+			Query:   "select status, count(*) as total from (select nullif(status, 'locked') as status from users union all select '') group by status",
+			Labels:  []string{"status"},
+			Values:  []string{"total"},
+			Timeout: time.Second,
+			TTL:     time.Hour,
+		},
+		{
+			Name:    "missing_column",
+			Query:   "select count(*) as n from users",
+			Values:  []string{"total"},
+			Timeout: time.Second,
+			TTL:     time.Hour,
+		},
+	})
+
+	want := `
+# HELP test_db_users_score Users by status.
+# TYPE test_db_users_score gauge
+test_db_users_score{db="sqlite",status="active"} 3.5
+test_db_users_score{db="sqlite",status="locked"} 0
+# HELP test_db_users_total Users by status.
+# TYPE test_db_users_total gauge
+test_db_users_total{db="sqlite",status="active"} 2
+test_db_users_total{db="sqlite",status="locked"} 1
+# HELP test_db_query_results_errors_total Number of failed runs of metric queries, by query.
+# TYPE test_db_query_results_errors_total counter
+test_db_query_results_errors_total{db="sqlite",query="broken"} 1
+test_db_query_results_errors_total{db="sqlite",query="duplicate_labels"} 1
+test_db_query_results_errors_total{db="sqlite",query="missing_column"} 1
+`
+	names := []string{"test_db_users_score", "test_db_users_total", "test_db_query_results_errors_total"}
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(want), names...))
+
+	// Results are cached for their TTL, so neither the new row nor the
+	// failing queries are run again.
+	_, err = db.Exec("INSERT INTO users VALUES ('d', 'active', 1)")
+	assert.NoError(t, err)
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(want), names...))
+	assert.Equal(t, 4, testutil.CollectAndCount(m, "test_db_query_results_duration_seconds"))
+	assert.Equal(t, 1, testutil.CollectAndCount(m, "test_db_query_results_last_success_timestamp_seconds"))
+}
+
+func TestQueryResultsMetrics_Run(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	m := NewQueryResultsCollector("test", "db", "sqlite", db, []QueryDefinition{
+		{Name: "answer", Query: "select 42 as v", Values: []string{"v"}, Timeout: time.Second, Interval: time.Hour},
+	})
+
+	want := `
+# HELP test_db_answer Result of the answer query.
+# TYPE test_db_answer gauge
+test_db_answer{db="sqlite"} 42
+`
+	assert.Equal(t, 0, testutil.CollectAndCount(m, "test_db_answer"), "background queries are not run on scrape")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCompare(m, strings.NewReader(want), "test_db_answer") == nil
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
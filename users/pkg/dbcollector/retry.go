@@ -0,0 +1,56 @@
+package dbcollector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = &RetryMetrics{}
+
+// RetryMetrics counts the statements retried after a transient error and
+// those given up on, labelled like QueryMetrics.
+type RetryMetrics struct {
+	Retries *prometheus.CounterVec
+	GiveUps *prometheus.CounterVec
+}
+
+func NewRetryCollector(namespace, subsystem, moduleName string) *RetryMetrics {
+	label := prometheus.Labels{"db": moduleName}
+	return &RetryMetrics{
+		Retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "query_retries_total",
+			Help:        "Number of SQL statements retried after a transient error, by query and error code.",
+			ConstLabels: label,
+		}, []string{"query", "code"}),
+		GiveUps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "query_retry_give_ups_total",
+			Help:        "Number of SQL statements that still failed with a transient error when out of attempts or time, by query and reason.",
+			ConstLabels: label,
+		}, []string{"query", "reason"}),
+	}
+}
+
+// Retry counts a retry of query, run with ctx, after an error with code.
+func (m *RetryMetrics) Retry(ctx context.Context, query, code string) {
+	m.Retries.WithLabelValues(queryLabel(ctx, query), code).Inc()
+}
+
+// GiveUp counts query, run with ctx, as given up for reason.
+func (m *RetryMetrics) GiveUp(ctx context.Context, query, reason string) {
+	m.GiveUps.WithLabelValues(queryLabel(ctx, query), reason).Inc()
+}
+
+func (m *RetryMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.Retries.Describe(ch)
+	m.GiveUps.Describe(ch)
+}
+
+func (m *RetryMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.Retries.Collect(ch)
+	m.GiveUps.Collect(ch)
+}
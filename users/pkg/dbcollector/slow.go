@@ -0,0 +1,40 @@
+package dbcollector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = &SlowQueryMetrics{}
+
+// SlowQueryMetrics counts the statements that took longer than the slow
+// query threshold, labelled like QueryMetrics.
+type SlowQueryMetrics struct {
+	Slow *prometheus.CounterVec
+}
+
+func NewSlowQueryCollector(namespace, subsystem, moduleName string) *SlowQueryMetrics {
+	return &SlowQueryMetrics{
+		Slow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "slow_queries_total",
+			Help:        "Number of SQL statements slower than the slow query threshold, by query.",
+			ConstLabels: prometheus.Labels{"db": moduleName},
+		}, []string{"query"}),
+	}
+}
+
+// Observe counts query, run with ctx, as slow.
+func (m *SlowQueryMetrics) Observe(ctx context.Context, query string) {
+	m.Slow.WithLabelValues(queryLabel(ctx, query)).Inc()
+}
+
+func (m *SlowQueryMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.Slow.Describe(ch)
+}
+
+func (m *SlowQueryMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.Slow.Collect(ch)
+}
@@ -0,0 +1,320 @@
+package dbcollector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = &SQLiteMetrics{}
+
+// SQLiteOptions configures NewSQLiteCollector. A zero interval disables the
+// matching periodic task.
+type SQLiteOptions struct {
+	// Timeout bounds the PRAGMA statements run on scrape, one second if
+	// zero.
+	Timeout time.Duration
+	// CheckpointInterval is how often a passive WAL checkpoint is run.
+	CheckpointInterval time.Duration
+	// QuickCheckInterval is how often PRAGMA quick_check is run. It reads
+	// the whole database, so it should run far less often than scrapes.
+	QuickCheckInterval time.Duration
+}
+
+// SQLiteMetrics reports the size of an SQLite database and the outcome of
+// periodic WAL checkpoints and integrity checks, run by Run.
+type SQLiteMetrics struct {
+	db           *sql.DB
+	opts         SQLiteOptions
+	quickChecked atomic.Bool
+
+	PageCount     *prometheus.Desc
+	FreelistCount *prometheus.Desc
+	PageSize      *prometheus.Desc
+	WALSize       *prometheus.Desc
+
+	StatsErrors        prometheus.Counter
+	Busy               *prometheus.CounterVec
+	Checkpoints        *prometheus.CounterVec
+	CheckpointDuration prometheus.Gauge
+	CheckpointFrames   *prometheus.GaugeVec
+	QuickCheckOK       prometheus.Gauge
+	QuickCheckLastRun  prometheus.Gauge
+	QuickCheckErrors   prometheus.Counter
+}
+
+func NewSQLiteCollector(namespace, subsystem, moduleName string, db *sql.DB, opts SQLiteOptions) *SQLiteMetrics {
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	label := prometheus.Labels{"db": moduleName}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, nil, label)
+	}
+	return &SQLiteMetrics{
+		db:            db,
+		opts:          opts,
+		PageCount:     desc("sqlite_page_count", "Number of pages in the database file."),
+		FreelistCount: desc("sqlite_freelist_count", "Number of unused pages in the database file."),
+		PageSize:      desc("sqlite_page_size_bytes", "Size of a database page."),
+		WALSize:       desc("sqlite_wal_size_bytes", "Size of the write-ahead log file, 0 without one."),
+		StatsErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_stats_errors_total",
+			Help:        "Number of scrapes that failed to read the database statistics.",
+			ConstLabels: label,
+		}),
+		Busy: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_busy_total",
+			Help:        "Number of statements that failed with SQLITE_BUSY or SQLITE_LOCKED, by code.",
+			ConstLabels: label,
+		}, []string{"code"}),
+		Checkpoints: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_checkpoints_total",
+			Help:        "Number of WAL checkpoints run, by result: ok, busy or error.",
+			ConstLabels: label,
+		}, []string{"result"}),
+		CheckpointDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_checkpoint_duration_seconds",
+			Help:        "Time taken by the last WAL checkpoint.",
+			ConstLabels: label,
+		}),
+		CheckpointFrames: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_checkpoint_frames",
+			Help:        "Frames in the WAL after the last checkpoint, by state: log for all of them and checkpointed for those written back to the database.",
+			ConstLabels: label,
+		}, []string{"state"}),
+		QuickCheckOK: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_quick_check_ok",
+			Help:        "Whether the last PRAGMA quick_check found the database intact.",
+			ConstLabels: label,
+		}),
+		QuickCheckLastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_quick_check_last_run_timestamp_seconds",
+			Help:        "Unix time of the last completed PRAGMA quick_check.",
+			ConstLabels: label,
+		}),
+		QuickCheckErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "sqlite_quick_check_errors_total",
+			Help:        "Number of PRAGMA quick_check runs that could not complete.",
+			ConstLabels: label,
+		}),
+	}
+}
+
+// SQLiteBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED and its
+// code, busy or locked. Either means another connection held a lock the
+// statement needed and the statement had no effect, so it is worth
+// retrying, unlike a constraint violation, a full disk or corruption.
+func SQLiteBusy(err error) (string, bool) {
+	var se sqlite3.Error
+	if !errors.As(err, &se) {
+		return "", false
+	}
+	switch se.Code {
+	case sqlite3.ErrBusy:
+		return "busy", true
+	case sqlite3.ErrLocked:
+		return "locked", true
+	}
+	return "", false
+}
+
+// ObserveError counts err if it is SQLITE_BUSY or SQLITE_LOCKED. Hook it to
+// the query collector with QueryMetrics.ObserveErrors.
+func (m *SQLiteMetrics) ObserveError(err error) {
+	if code, ok := SQLiteBusy(err); ok {
+		m.Busy.WithLabelValues(code).Inc()
+	}
+}
+
+// Run checkpoints and checks the database on their intervals until ctx is
+// done.
+func (m *SQLiteMetrics) Run(ctx context.Context) error {
+	var checkpoint, quickCheck <-chan time.Time
+	if m.opts.CheckpointInterval > 0 {
+		t := time.NewTicker(m.opts.CheckpointInterval)
+		defer t.Stop()
+		checkpoint = t.C
+	}
+	if m.opts.QuickCheckInterval > 0 {
+		t := time.NewTicker(m.opts.QuickCheckInterval)
+		defer t.Stop()
+		quickCheck = t.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-checkpoint:
+			m.Checkpoint(ctx)
+		case <-quickCheck:
+			m.QuickCheck(ctx)
+		}
+	}
+}
+
+// Checkpoint runs a passive WAL checkpoint, which copies to the database
+// what it can without waiting for readers or writers.
+func (m *SQLiteMetrics) Checkpoint(ctx context.Context) {
+	var busy, log, checkpointed int
+	start := time.Now()
+	err := m.db.QueryRowContext(WithQueryName(ctx, "sqlite.checkpoint"), "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &log, &checkpointed)
+	m.CheckpointDuration.Set(time.Since(start).Seconds())
+
+	switch {
+	case err != nil:
+		m.Checkpoints.WithLabelValues("error").Inc()
+		return
+	case busy != 0:
+		m.Checkpoints.WithLabelValues("busy").Inc()
+	default:
+		m.Checkpoints.WithLabelValues("ok").Inc()
+	}
+	// Both are -1 when the database is not in WAL mode.
+	if log >= 0 {
+		m.CheckpointFrames.WithLabelValues("log").Set(float64(log))
+		m.CheckpointFrames.WithLabelValues("checkpointed").Set(float64(checkpointed))
+	}
+}
+
+// QuickCheck runs PRAGMA quick_check, which reports ok for an intact
+// database and otherwise one row per problem found.
+func (m *SQLiteMetrics) QuickCheck(ctx context.Context) {
+	rows, err := m.db.QueryContext(WithQueryName(ctx, "sqlite.quick_check"), "PRAGMA quick_check")
+	if err != nil {
+		m.QuickCheckErrors.Inc()
+		return
+	}
+	defer rows.Close()
+
+	var results []string
+	for rows.Next() {
+		var r string
+		if err := rows.Scan(&r); err != nil {
+			m.QuickCheckErrors.Inc()
+			return
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		m.QuickCheckErrors.Inc()
+		return
+	}
+
+	ok := 0.0
+	if len(results) == 1 && results[0] == "ok" {
+		ok = 1
+	}
+	m.QuickCheckOK.Set(ok)
+	m.QuickCheckLastRun.SetToCurrentTime()
+	m.quickChecked.Store(true)
+}
+
+type sqliteStats struct {
+	pageCount, freelistCount, pageSize int64
+	walSize                            int64
+}
+
+func (m *SQLiteMetrics) stats() (sqliteStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+	defer cancel()
+	ctx = WithQueryName(ctx, "sqlite.stats")
+
+	var s sqliteStats
+	for _, p := range []struct {
+		pragma string
+		dest   *int64
+	}{
+		{"page_count", &s.pageCount},
+		{"freelist_count", &s.freelistCount},
+		{"page_size", &s.pageSize},
+	} {
+		if err := m.db.QueryRowContext(ctx, "PRAGMA "+p.pragma).Scan(p.dest); err != nil {
+			return s, fmt.Errorf("failed to read %s: %w", p.pragma, err)
+		}
+	}
+
+	var (
+		seq        int
+		name, file string
+	)
+	if err := m.db.QueryRowContext(ctx, "PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		return s, fmt.Errorf("failed to read database file: %w", err)
+	}
+	// In-memory and temporary databases have no file.
+	if file != "" && !strings.HasPrefix(file, ":memory:") {
+		fi, err := os.Stat(file + "-wal")
+		switch {
+		case err == nil:
+			s.walSize = fi.Size()
+		case !errors.Is(err, os.ErrNotExist):
+			return s, fmt.Errorf("failed to read WAL size: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func (m *SQLiteMetrics) Collect(ch chan<- prometheus.Metric) {
+	if s, err := m.stats(); err != nil {
+		m.StatsErrors.Inc()
+		slog.Warn("failed to collect sqlite stats", "error", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(m.PageCount, prometheus.GaugeValue, float64(s.pageCount))
+		ch <- prometheus.MustNewConstMetric(m.FreelistCount, prometheus.GaugeValue, float64(s.freelistCount))
+		ch <- prometheus.MustNewConstMetric(m.PageSize, prometheus.GaugeValue, float64(s.pageSize))
+		ch <- prometheus.MustNewConstMetric(m.WALSize, prometheus.GaugeValue, float64(s.walSize))
+	}
+
+	m.StatsErrors.Collect(ch)
+	m.Busy.Collect(ch)
+	m.Checkpoints.Collect(ch)
+	m.CheckpointDuration.Collect(ch)
+	m.CheckpointFrames.Collect(ch)
+	// Not exported before the first check, which would read as corruption.
+	if m.quickChecked.Load() {
+		m.QuickCheckOK.Collect(ch)
+		m.QuickCheckLastRun.Collect(ch)
+	}
+	m.QuickCheckErrors.Collect(ch)
+}
+
+func (m *SQLiteMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.PageCount
+	ch <- m.FreelistCount
+	ch <- m.PageSize
+	ch <- m.WALSize
+	m.StatsErrors.Describe(ch)
+	m.Busy.Describe(ch)
+	m.Checkpoints.Describe(ch)
+	m.CheckpointDuration.Describe(ch)
+	m.CheckpointFrames.Describe(ch)
+	m.QuickCheckOK.Describe(ch)
+	m.QuickCheckLastRun.Describe(ch)
+	m.QuickCheckErrors.Describe(ch)
+}
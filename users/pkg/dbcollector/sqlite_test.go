@@ -0,0 +1,145 @@
+package dbcollector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteMetrics(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_journal_mode=WAL")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("PRAGMA page_size = 4096; CREATE TABLE users (id TEXT); INSERT INTO users VALUES ('a'), ('b')")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	m := NewSQLiteCollector("test", "db", "sqlite", db, SQLiteOptions{})
+	assert.Equal(t, 0, testutil.CollectAndCount(m, "test_db_sqlite_quick_check_ok"), "not exported before the first check")
+
+	ctx := context.Background()
+	m.Checkpoint(ctx)
+	m.QuickCheck(ctx)
+
+	want := `
+# HELP test_db_sqlite_page_size_bytes Size of a database page.
+# TYPE test_db_sqlite_page_size_bytes gauge
+test_db_sqlite_page_size_bytes{db="sqlite"} 4096
+# HELP test_db_sqlite_freelist_count Number of unused pages in the database file.
+# TYPE test_db_sqlite_freelist_count gauge
+test_db_sqlite_freelist_count{db="sqlite"} 0
+# HELP test_db_sqlite_checkpoints_total Number of WAL checkpoints run, by result: ok, busy or error.
+# TYPE test_db_sqlite_checkpoints_total counter
+test_db_sqlite_checkpoints_total{db="sqlite",result="ok"} 1
+# HELP test_db_sqlite_quick_check_ok Whether the last PRAGMA quick_check found the database intact.
+# TYPE test_db_sqlite_quick_check_ok gauge
+test_db_sqlite_quick_check_ok{db="sqlite"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(want),
+		"test_db_sqlite_page_size_bytes",
+		"test_db_sqlite_freelist_count",
+		"test_db_sqlite_checkpoints_total",
+		"test_db_sqlite_quick_check_ok",
+	))
+
+	var pages float64
+	assert.NoError(t, db.QueryRow("PRAGMA page_count").Scan(&pages))
+	assert.Equal(t, pages, gaugeValue(t, m, "test_db_sqlite_page_count"))
+	assert.Positive(t, gaugeValue(t, m, "test_db_sqlite_wal_size_bytes"))
+	assert.Positive(t, testutil.ToFloat64(m.CheckpointFrames.WithLabelValues("log")))
+}
+
+func gaugeValue(t *testing.T, c prometheus.Collector, name string) float64 {
+	r := prometheus.NewPedanticRegistry()
+	assert.NoError(t, r.Register(c))
+	families, err := r.Gather()
+	assert.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == name {
+			return f.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Errorf("metric %s not found", name)
+	return 0
+}
+
+func TestSQLiteBusy(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+		want     bool
+	}{
+		{name: "busy", err: sqlite3.Error{Code: sqlite3.ErrBusy}, wantCode: "busy", want: true},
+		{name: "busy snapshot", err: sqlite3.Error{Code: sqlite3.ErrBusy, ExtendedCode: sqlite3.ErrBusySnapshot}, wantCode: "busy", want: true},
+		{name: "wrapped locked", err: fmt.Errorf("failed: %w", sqlite3.Error{Code: sqlite3.ErrLocked}), wantCode: "locked", want: true},
+		{name: "constraint", err: sqlite3.Error{Code: sqlite3.ErrConstraint}},
+		{name: "full", err: sqlite3.Error{Code: sqlite3.ErrFull}},
+		{name: "not sqlite", err: context.DeadlineExceeded},
+		{name: "nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := SQLiteBusy(tt.err)
+			assert.Equal(t, tt.want, ok)
+			assert.Equal(t, tt.wantCode, code)
+		})
+	}
+}
+
+func TestSQLiteMetrics_ObserveError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+	}{
+		{
+			name:     "busy",
+			err:      sqlite3.Error{Code: sqlite3.ErrBusy},
+			wantCode: "busy",
+		},
+		{
+			name:     "wrapped locked",
+			err:      fmt.Errorf("failed to update user: %w", sqlite3.Error{Code: sqlite3.ErrLocked}),
+			wantCode: "locked",
+		},
+		{
+			name: "other sqlite error",
+			err:  sqlite3.Error{Code: sqlite3.ErrConstraint},
+		},
+		{
+			name: "not an sqlite error",
+			err:  errors.New("failed"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewSQLiteCollector("test", "db", "sqlite", nil, SQLiteOptions{})
+			m.ObserveError(tt.err)
+
+			want := ""
+			if tt.wantCode != "" {
+				want = fmt.Sprintf(`
+# HELP test_db_sqlite_busy_total Number of statements that failed with SQLITE_BUSY or SQLITE_LOCKED, by code.
+# TYPE test_db_sqlite_busy_total counter
+test_db_sqlite_busy_total{code="%s",db="sqlite"} 1
+`, tt.wantCode)
+			}
+			assert.NoError(t, testutil.CollectAndCompare(m.Busy, strings.NewReader(want)))
+		})
+	}
+}
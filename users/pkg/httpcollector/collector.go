@@ -0,0 +1,207 @@
+// Package httpcollector records the rate, errors and duration of HTTP
+// requests served through a chi router.
+package httpcollector
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = &HTTPMetrics{}
+
+// unmatchedRoute labels requests that matched no route, so that scanners
+// probing random paths don't create a series per path.
+const unmatchedRoute = "unmatched"
+
+// Options tunes HTTPMetrics. Buckets left empty get defaults.
+type Options struct {
+	// DurationBuckets are the upper bounds of the latency histogram, in
+	// seconds.
+	DurationBuckets []float64
+	// SizeBuckets are the upper bounds of the request and response size
+	// histograms, in bytes.
+	SizeBuckets []float64
+	// Exemplar returns the labels of the exemplar attached to the latency
+	// observation of r, typically its trace ID, or nil for none.
+	Exemplar func(r *http.Request) prometheus.Labels
+}
+
+var (
+	DefaultDurationBuckets = prometheus.DefBuckets
+	DefaultSizeBuckets     = prometheus.ExponentialBuckets(100, 10, 6)
+)
+
+type HTTPMetrics struct {
+	exemplar func(r *http.Request) prometheus.Labels
+
+	Requests     *prometheus.CounterVec
+	InFlight     *prometheus.GaugeVec
+	Duration     *prometheus.HistogramVec
+	RequestSize  *prometheus.HistogramVec
+	ResponseSize *prometheus.HistogramVec
+}
+
+func NewHTTPCollector(namespace, subsystem string, opts Options) *HTTPMetrics {
+	if len(opts.DurationBuckets) == 0 {
+		opts.DurationBuckets = DefaultDurationBuckets
+	}
+	if len(opts.SizeBuckets) == 0 {
+		opts.SizeBuckets = DefaultSizeBuckets
+	}
+	labels := []string{"method", "route", "status"}
+
+	return &HTTPMetrics{
+		exemplar: opts.Exemplar,
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests served, by method, route and status class.",
+		}, labels),
+		InFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests being served, by method.",
+		}, []string{"method"}),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by method, route and status class.",
+			Buckets:   opts.DurationBuckets,
+		}, labels),
+		RequestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_size_bytes",
+			Help:      "Size of HTTP request bodies, by method, route and status class.",
+			Buckets:   opts.SizeBuckets,
+		}, labels),
+		ResponseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "response_size_bytes",
+			Help:      "Size of HTTP response bodies, by method, route and status class.",
+			Buckets:   opts.SizeBuckets,
+		}, labels),
+	}
+}
+
+// Middleware records every request served by next. It has to be used on the
+// root router so that the route pattern is complete once next returns.
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		method := methodLabel(r.Method)
+		inFlight := m.InFlight.WithLabelValues(method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"method": method,
+			"route":  routeLabel(r),
+			"status": statusClass(status),
+		}
+
+		m.Requests.With(labels).Inc()
+		observe(m.Duration.With(labels), time.Since(start).Seconds(), m.exemplarOf(r))
+		if r.ContentLength >= 0 {
+			m.RequestSize.With(labels).Observe(float64(r.ContentLength))
+		}
+		m.ResponseSize.With(labels).Observe(float64(ww.BytesWritten()))
+	})
+}
+
+func (m *HTTPMetrics) exemplarOf(r *http.Request) prometheus.Labels {
+	if m.exemplar == nil {
+		return nil
+	}
+	return m.exemplar(r)
+}
+
+func observe(o prometheus.Observer, v float64, exemplar prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && len(exemplar) > 0 {
+		eo.ObserveWithExemplar(v, exemplar)
+		return
+	}
+	o.Observe(v)
+}
+
+// RoutePattern returns the chi route pattern matched by r, like
+// /users/{id}, or an empty string when no route matched. chi joins the
+// patterns of nested routers as they are, so the root of a sub-router mounted
+// on /users comes out as /users//, which is cleaned up to /users.
+func RoutePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+
+	pattern := rctx.RoutePattern()
+	for strings.Contains(pattern, "//") {
+		pattern = strings.ReplaceAll(pattern, "//", "/")
+	}
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return pattern
+}
+
+// routeLabel returns the route label of r, unmatchedRoute when no route
+// matched.
+func routeLabel(r *http.Request) string {
+	if pattern := RoutePattern(r); pattern != "" {
+		return pattern
+	}
+	return unmatchedRoute
+}
+
+// otherMethod is the method label of requests with a non-standard method, so
+// that clients can't create series by making up methods.
+const otherMethod = "other"
+
+// methodLabel returns method if it is one of the methods of RFC 9110 or
+// PATCH and otherMethod if not.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return otherMethod
+}
+
+// statusClass turns 404 into 4xx.
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func (m *HTTPMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.Requests.Describe(ch)
+	m.InFlight.Describe(ch)
+	m.Duration.Describe(ch)
+	m.RequestSize.Describe(ch)
+	m.ResponseSize.Describe(ch)
+}
+
+func (m *HTTPMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.Requests.Collect(ch)
+	m.InFlight.Collect(ch)
+	m.Duration.Collect(ch)
+	m.RequestSize.Collect(ch)
+	m.ResponseSize.Collect(ch)
+}
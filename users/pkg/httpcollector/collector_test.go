@@ -0,0 +1,112 @@
+package httpcollector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMetrics_Middleware(t *testing.T) {
+	m := NewHTTPCollector("test", "http", Options{
+		DurationBuckets: []float64{0.1, 1},
+		SizeBuckets:     []float64{10, 100},
+		Exemplar: func(r *http.Request) prometheus.Labels {
+			return prometheus.Labels{"trace_id": r.Header.Get("X-Trace")}
+		},
+	})
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "", http.StatusBadRequest)
+		})
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		req := httptest.NewRequest("GET", "/users/"+id, nil)
+		req.Header.Set("X-Trace", "abc")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users/", strings.NewReader(`{"name":"mike"}`)))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope", nil))
+
+	want := `
+# HELP test_http_requests_total Number of HTTP requests served, by method, route and status class.
+# TYPE test_http_requests_total counter
+test_http_requests_total{method="GET",route="/users/{id}",status="2xx"} 3
+test_http_requests_total{method="GET",route="unmatched",status="4xx"} 1
+test_http_requests_total{method="POST",route="/users",status="4xx"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(want), "test_http_requests_total"))
+
+	want = `
+# HELP test_http_request_size_bytes Size of HTTP request bodies, by method, route and status class.
+# TYPE test_http_request_size_bytes histogram
+test_http_request_size_bytes_bucket{method="GET",route="/users/{id}",status="2xx",le="10"} 3
+test_http_request_size_bytes_bucket{method="GET",route="/users/{id}",status="2xx",le="100"} 3
+test_http_request_size_bytes_bucket{method="GET",route="/users/{id}",status="2xx",le="+Inf"} 3
+test_http_request_size_bytes_sum{method="GET",route="/users/{id}",status="2xx"} 0
+test_http_request_size_bytes_count{method="GET",route="/users/{id}",status="2xx"} 3
+test_http_request_size_bytes_bucket{method="GET",route="unmatched",status="4xx",le="10"} 1
+test_http_request_size_bytes_bucket{method="GET",route="unmatched",status="4xx",le="100"} 1
+test_http_request_size_bytes_bucket{method="GET",route="unmatched",status="4xx",le="+Inf"} 1
+test_http_request_size_bytes_sum{method="GET",route="unmatched",status="4xx"} 0
+test_http_request_size_bytes_count{method="GET",route="unmatched",status="4xx"} 1
+test_http_request_size_bytes_bucket{method="POST",route="/users",status="4xx",le="10"} 0
+test_http_request_size_bytes_bucket{method="POST",route="/users",status="4xx",le="100"} 1
+test_http_request_size_bytes_bucket{method="POST",route="/users",status="4xx",le="+Inf"} 1
+test_http_request_size_bytes_sum{method="POST",route="/users",status="4xx"} 15
+test_http_request_size_bytes_count{method="POST",route="/users",status="4xx"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(want), "test_http_request_size_bytes"))
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.InFlight.WithLabelValues("GET")))
+
+	var metric dto.Metric
+	assert.NoError(t, m.Duration.WithLabelValues("GET", "/users/{id}", "2xx").(prometheus.Histogram).Write(&metric))
+	var exemplars int
+	for _, b := range metric.GetHistogram().GetBucket() {
+		if e := b.GetExemplar(); e != nil {
+			exemplars++
+			assert.Equal(t, "trace_id", e.GetLabel()[0].GetName())
+			assert.Equal(t, "abc", e.GetLabel()[0].GetValue())
+		}
+	}
+	assert.Equal(t, 1, exemplars)
+}
+
+func TestHTTPMetrics_Middleware_methods(t *testing.T) {
+	m := NewHTTPCollector("test", "http", Options{
+		DurationBuckets: []float64{0.1, 1},
+		SizeBuckets:     []float64{10, 100},
+	})
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, method := range []string{"GET", "PATCH", "FOO", "BREW", "get"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+
+	want := `
+# HELP test_http_requests_total Number of HTTP requests served, by method, route and status class.
+# TYPE test_http_requests_total counter
+test_http_requests_total{method="GET",route="/",status="2xx"} 1
+test_http_requests_total{method="PATCH",route="unmatched",status="4xx"} 1
+test_http_requests_total{method="other",route="unmatched",status="4xx"} 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(want), "test_http_requests_total"))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.InFlight.WithLabelValues("other")))
+}